@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// RefLine is an added line that the file already has on the -added-vs
+// branch, where it may have been changed differently.
+type RefLine struct {
+	Text string
+	// Line of the file on the branch and the commit that last changed it
+	Line   int
+	Commit string
+}
+
+// linesOnRef returns the lines added by the diff that the file already has
+// in ref. Lines without letters or digits, such as blank lines or closing
+// braces, are left out: they are found anywhere.
+func linesOnRef(file, ref string, diff unidiff.Diff) []RefLine {
+	content := blobContent(ref, file)
+	if content == "" {
+		return nil
+	}
+	lineOf := map[string]int{}
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if _, ok := lineOf[line]; !ok {
+			lineOf[line] = i + 1
+		}
+	}
+	var found []RefLine
+	var blame Blame
+	for _, hunk := range diff.Hunks {
+		for _, line := range hunk.AddedLines() {
+			text := string(line)
+			lnum, ok := lineOf[text]
+			if !ok || strings.IndexFunc(text, isAlnum) < 0 {
+				continue
+			}
+			if blame == nil {
+				blame = getBlame(file, ref, nil)
+			}
+			var sha1 string
+			if lnum < len(blame) {
+				sha1 = blame.sha1(lnum)
+			}
+			found = append(found, RefLine{Text: text, Line: lnum, Commit: sha1})
+		}
+	}
+	return found
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func showLinesOnRef(lines []RefLine, ref string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Printf("    Added lines already on %s:\n", ref)
+	for _, l := range lines {
+		fmt.Printf("\t%s line %d: %s\n", shortSha1(l.Commit), l.Line, strings.TrimSpace(l.Text))
+	}
+}
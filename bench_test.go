@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const benchFileLines = 50
+
+// buildBenchRepo creates a repository with the given number of commits
+// spread over the files, merge base tags and origin/release-N branches at
+// regular intervals, and local changes to every file. It returns the
+// repository's directory and the changed files.
+func buildBenchRepo(b *testing.B, commits, files, tags int) (string, []string) {
+	b.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git not found")
+	}
+	dir := b.TempDir()
+	git := func(stdin []byte, args ...string) {
+		b.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Stdin = bytes.NewReader(stdin)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			b.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git(nil, "init", "-q", "-b", "develop")
+
+	contents := make([][]string, files)
+	names := make([]string, files)
+	for f := range contents {
+		names[f] = fmt.Sprintf("dir%d/file%d.txt", f%10, f)
+		contents[f] = make([]string, benchFileLines)
+		for l := range contents[f] {
+			contents[f][l] = fmt.Sprintf("file %d line %d", f, l)
+		}
+	}
+
+	// Build the history with a single git fast-import
+	stream := &bytes.Buffer{}
+	release := 0
+	for i := 1; i <= commits; i++ {
+		fmt.Fprintf(stream, "commit refs/heads/develop\nmark :%d\n", i)
+		fmt.Fprintf(stream, "committer Bench <bench@example.com> %d +0000\n", 1500000000+i*3600)
+		msg := fmt.Sprintf("Commit %d", i)
+		fmt.Fprintf(stream, "data %d\n%s\n", len(msg), msg)
+		changed := 1
+		if i == 1 {
+			changed = files
+		}
+		for c := 0; c < changed; c++ {
+			f := (i + c) % files
+			l := (i / files * 7) % benchFileLines
+			contents[f][l] = fmt.Sprintf("file %d line %d changed by %d", f, l, i)
+			data := strings.Join(contents[f], "\n") + "\n"
+			fmt.Fprintf(stream, "M 100644 inline %s\ndata %d\n%s\n", names[f], len(data), data)
+		}
+		if tags > 0 && i%(commits/tags) == 0 && release < tags {
+			release++
+			fmt.Fprintf(stream, "reset refs/tags/MERGE_BASE_%d\nfrom :%d\n\n", release, i)
+			fmt.Fprintf(stream, "reset refs/remotes/origin/release-%d\nfrom :%d\n\n", release, i)
+		}
+	}
+	fmt.Fprintf(stream, "reset refs/remotes/origin/develop\nfrom :%d\n\n", commits)
+	git(stream.Bytes(), "fast-import", "--quiet")
+	git(nil, "checkout", "-q", "-f", "develop")
+
+	// Change every fourth line of every file
+	for f, name := range names {
+		for l := 0; l < benchFileLines; l += 4 {
+			contents[f][l] += " modified"
+		}
+		data := strings.Join(contents[f], "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir, names
+}
+
+// benchSetup runs the benchmark inside the repository with the state
+// main would set up, and clears the caches so that every iteration does
+// the same work.
+func benchSetup(b *testing.B, dir string, useCommitGraph bool) {
+	b.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		os.Chdir(wd)
+		commitGraph = nil
+	})
+
+	remotes = []string{"origin"}
+	defaultBranches = []string{"origin/develop"}
+	commitGraph = nil
+	if useCommitGraph {
+		run("git", "commit-graph", "write", "--reachable")
+		commitGraph = loadCommitGraph()
+		if commitGraph == nil {
+			b.Fatal("no commit-graph")
+		}
+	}
+}
+
+func resetCaches() {
+	blameCache = cache[Blame]{}
+	parentsCache = cache[[]string]{}
+	relatedCache = cache[[]RelatedCommit]{}
+	mergeBaseTagsCache = cache[MergeBaseTags]{}
+	releaseTagsCache = cache[ReleaseTags]{}
+	branchesCache = cache[[]string]{}
+	dateCache = cache[time.Time]{}
+	authorCache = cache[string]{}
+	subjectCache = cache[string]{}
+	issuesCache = cache[[]string]{}
+	firstReleaseCache = cache[string]{}
+	checkedBranchesCache = cache[[]branchRef]{}
+	projectCache = cache[*Project]{}
+	containingCache = cache[[]string]{}
+	forkDepthCache = cache[int]{}
+	distanceCache = cache[Distance]{}
+	tagRefs = map[string][]tagRef{}
+}
+
+func benchmarkAnalyze(b *testing.B, commits, files, tags int) {
+	dir, names := buildBenchRepo(b, commits, files, tags)
+	for _, useCommitGraph := range []bool{false, true} {
+		name := "subprocess"
+		if useCommitGraph {
+			name = "commit-graph"
+		}
+		b.Run(name, func(b *testing.B) {
+			benchSetup(b, dir, useCommitGraph)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resetCaches()
+				for result := range analyzeFiles(names, nil, worktreeSpec()) {
+					if len(result.report.Commits) == 0 {
+						b.Fatalf("%s: no affected commits", result.report.File)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyzeSmall(b *testing.B) { benchmarkAnalyze(b, 100, 5, 5) }
+
+func BenchmarkAnalyzeLarge(b *testing.B) { benchmarkAnalyze(b, 1000, 20, 10) }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// BlameContext is the blame of the lines around the lines changed by a
+// hunk, to judge which commit the change really depends on, as -B and -A
+// or -neighbor decide otherwise.
+type BlameContext struct {
+	// First line shown
+	First int
+	// Commit and content of each line shown
+	Commits []string
+	Lines   []string
+	// Lines removed, and lines after which lines are added
+	Removed  map[int]bool
+	Inserted map[int]bool
+}
+
+// blameContext returns the blame of the lines each hunk of the diff
+// changes in rev, with n lines around them.
+func blameContext(file, rev string, diff unidiff.Diff, blame Blame, n int) []BlameContext {
+	content := strings.Split(blobContent(rev, file), "\n")
+	var contexts []BlameContext
+	for _, hunk := range diff.Hunks {
+		start, end := changedRange(hunk)
+		if start == 0 || start >= len(blame) {
+			continue
+		}
+		first, last := start-n, end+n
+		if first < 1 {
+			first = 1
+		}
+		if last >= len(blame) {
+			last = len(blame) - 1
+		}
+		c := BlameContext{First: first, Removed: map[int]bool{}, Inserted: map[int]bool{}}
+		for lnum := first; lnum <= last; lnum++ {
+			var line string
+			if lnum <= len(content) {
+				line = strings.TrimSuffix(content[lnum-1], "\r")
+			}
+			c.Commits = append(c.Commits, blame.sha1(lnum))
+			c.Lines = append(c.Lines, line)
+		}
+		for _, lnum := range hunk.Parents[0].Removed {
+			c.Removed[lnum] = true
+		}
+		for _, lnum := range hunk.Parents[0].Inserted {
+			c.Inserted[lnum] = true
+		}
+		contexts = append(contexts, c)
+	}
+	return contexts
+}
+
+func showBlameContext(contexts []BlameContext) {
+	for _, c := range contexts {
+		fmt.Printf("    Blame of lines %d-%d:\n", c.First, c.First+len(c.Lines)-1)
+		if c.Inserted[0] {
+			fmt.Printf("\t+ %s\n", "(lines added)")
+		}
+		for i, line := range c.Lines {
+			lnum := c.First + i
+			mark := " "
+			if c.Removed[lnum] {
+				mark = "-"
+			}
+			fmt.Printf("\t%s %s %4d %s\n", mark, shortSha1(c.Commits[i]), lnum, line)
+			if c.Inserted[lnum] {
+				fmt.Printf("\t+ %s\n", "(lines added)")
+			}
+		}
+	}
+}
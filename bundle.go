@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bundleRefs is the namespace the refs of a bundle are fetched into while
+// it is checked.
+const bundleRefs = "refs/check-diff/bundle/"
+
+// checkBundle fetches the refs of the bundle file into a temporary
+// namespace and checks the commits it brings that are not already in the
+// repository, as if they were a patch series.
+func checkBundle(file string) {
+	if _, err := gitOutput("bundle", "verify", file); err != nil {
+		bail("error: %s cannot be used with this repository", file)
+	}
+
+	var refspecs, refs []string
+	for _, line := range linesFrom("git", "bundle", "list-heads", file) {
+		fields := strings.Fields(string(line))
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/") {
+			continue
+		}
+		ref := bundleRefs + strings.TrimPrefix(fields[1], "refs/")
+		refspecs = append(refspecs, "+"+fields[1]+":"+ref)
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		bail("error: %s has no refs", file)
+	}
+	onExit(func() {
+		for _, ref := range refs {
+			gitOutput("update-ref", "-d", ref)
+		}
+	})
+	args := fetchArgs(append([]string{file}, refspecs...)...)
+	if _, err := gitEnv(nil, args...); err != nil {
+		bail("error: fetching %s: %v", file, err)
+	}
+
+	revs := append(refs, "--not", "--exclude="+bundleRefs+"*", "--all")
+	commits, reports := analyzeRange(revs...)
+	fmt.Printf("%s: %d new commits\n", file, len(commits))
+	for _, r := range reports {
+		fmt.Println()
+		showReport(r)
+	}
+	if len(reports) > 0 {
+		fmt.Println()
+		showSummary("", reports, commonTagsOf(reports))
+	}
+}
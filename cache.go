@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// cache memoizes git lookups for the duration of a run, so that files
+// sharing affected commits don't repeat the same git commands. Cached
+// values are shared and must not be modified.
+type cache[V any] struct {
+	mu sync.Mutex
+	m  map[string]V
+}
+
+// get returns the cached value for key, calling lookup to get it the
+// first time.
+func (c *cache[V]) get(key string, lookup func() V) V {
+	if v, ok := c.cached(key); ok {
+		return v
+	}
+	v := lookup()
+	c.set(key, v)
+	return v
+}
+
+func (c *cache[V]) cached(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *cache[V]) set(key string, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = map[string]V{}
+	}
+	c.m[key] = v
+}
+
+var (
+	blameCache         cache[Blame]
+	mergeBaseTagsCache cache[MergeBaseTags]
+	releaseTagsCache   cache[ReleaseTags]
+	branchesCache      cache[[]string]
+	dateCache          cache[time.Time]
+	authorCache        cache[string]
+	subjectCache       cache[string]
+	issuesCache        cache[[]string]
+	firstReleaseCache  cache[string]
+)
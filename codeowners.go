@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// CodeOwners holds the rules of a CODEOWNERS file, in file order.
+type CodeOwners []ownerRule
+
+// loadCodeOwners reads the CODEOWNERS file from the usual locations in the
+// repository's work tree. It returns nil if there is none.
+func loadCodeOwners() CodeOwners {
+	top := strings.TrimSpace(string(run("git", "rev-parse", "--show-toplevel")))
+	for _, name := range []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"} {
+		buf, err := ioutil.ReadFile(filepath.Join(top, name))
+		if err == nil {
+			return parseCodeOwners(buf)
+		}
+	}
+	return nil
+}
+
+func parseCodeOwners(buf []byte) CodeOwners {
+	var c CodeOwners
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		c = append(c, ownerRule{
+			pattern: ownerPatternRegexp(fields[0]),
+			owners:  fields[1:],
+		})
+	}
+	return c
+}
+
+// ownerPatternRegexp converts a gitignore style CODEOWNERS pattern into a
+// regular expression matching repository relative paths.
+func ownerPatternRegexp(pattern string) *regexp.Regexp {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	re := &bytes.Buffer{}
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if dir {
+		re.WriteString("/.*$")
+	} else {
+		re.WriteString("(?:/.*)?$")
+	}
+	return regexp.MustCompile(re.String())
+}
+
+// owners returns the owners of the repository relative path. As in
+// GitHub, the last matching rule wins.
+func (c CodeOwners) owners(path string) []string {
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].pattern.MatchString(path) {
+			return c[i].owners
+		}
+	}
+	return nil
+}
+
+// ownersOf returns the owners of all the paths, without duplicates.
+func (c CodeOwners) ownersOf(paths []string) []string {
+	var owners []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		for _, owner := range c.owners(path) {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// repoPath returns the path of file relative to the top of the repository.
+func repoPath(file string) string {
+	prefix := strings.TrimSpace(string(run("git", "rev-parse", "--show-prefix")))
+	return filepath.ToSlash(filepath.Clean(filepath.Join(prefix, file)))
+}
+
+// getCommitPaths returns the paths modified by the commit.
+func getCommitPaths(sha1 string) []string {
+	var paths []string
+	for _, line := range linesFrom("git", "show", "--format=", "--name-only", sha1) {
+		if len(line) > 0 {
+			paths = append(paths, string(line))
+		}
+	}
+	return paths
+}
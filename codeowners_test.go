@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	c := parseCodeOwners([]byte(`# Default owners
+*       @global
+
+*.go    @gophers # Go code
+/docs/  @writers
+build/  @builders
+apps/**/test @testers
+/README.md
+`))
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"main.go", []string{"@gophers"}},
+		{"cmd/tool/main.go", []string{"@gophers"}},
+		{"docs/index.md", []string{"@writers"}},
+		{"src/docs/index.md", []string{"@global"}},
+		{"build/Makefile", []string{"@builders"}},
+		{"src/build/Makefile", []string{"@builders"}},
+		{"apps/test/a.txt", []string{"@testers"}},
+		{"apps/x/y/test/a.txt", []string{"@testers"}},
+		{"README.md", []string{}},
+		{"LICENSE", []string{"@global"}},
+	}
+
+	for i, tt := range tests {
+		got := c.owners(tt.path)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tests[%d] %s failed\nwant: %q\n got: %q", i, tt.path, tt.want, got)
+		}
+	}
+}
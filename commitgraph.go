@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CommitGraph is an in-memory view of git's commit-graph file, used to
+// answer containment questions without running git. See
+// Documentation/gitformat-commit-graph.txt in git.
+type CommitGraph struct {
+	hashLen int
+	fanout  []byte
+	oids    []byte
+	data    []byte
+	edges   []byte
+	n       uint32
+}
+
+const (
+	graphParentNone  = 0x70000000
+	graphExtraEdges  = 0x80000000
+	graphLastEdge    = 0x80000000
+	graphChunkOIDF   = 0x4f494446
+	graphChunkOIDL   = 0x4f49444c
+	graphChunkCDAT   = 0x43444154
+	graphChunkEDGE   = 0x45444745
+	graphHeaderSize  = 8
+	graphChunkRecLen = 12
+)
+
+// commitGraph is nil when the repository has no (single file) commit-graph
+// or it is disabled with -no-commit-graph.
+var commitGraph *CommitGraph
+
+// loadCommitGraph reads the repository's commit-graph file, if any.
+func loadCommitGraph() *CommitGraph {
+	filename := strings.TrimSpace(string(run("git", "rev-parse", "--git-path", "objects/info/commit-graph")))
+	g, err := readCommitGraph(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warn("warning: %s: %v", filename, err)
+		}
+		slog.Debug("no commit-graph", "file", filename, "err", err)
+		return nil
+	}
+	slog.Info("using the commit-graph", "file", filename, "commits", g.n)
+	return g
+}
+
+func readCommitGraph(filename string) (*CommitGraph, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < graphHeaderSize || !bytes.Equal(buf[0:4], []byte("CGPH")) {
+		return nil, errors.New("not a commit-graph file")
+	}
+	if buf[4] != 1 {
+		return nil, fmt.Errorf("unsupported commit-graph version %d", buf[4])
+	}
+	g := &CommitGraph{}
+	switch buf[5] {
+	case 1:
+		g.hashLen = 20
+	case 2:
+		g.hashLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported commit-graph hash version %d", buf[5])
+	}
+	nChunks := int(buf[6])
+	if buf[7] != 0 {
+		return nil, errors.New("split commit-graphs are not supported")
+	}
+
+	toc := buf[graphHeaderSize:]
+	if len(toc) < (nChunks+1)*graphChunkRecLen {
+		return nil, errors.New("truncated chunk table")
+	}
+	for i := 0; i < nChunks; i++ {
+		rec := toc[i*graphChunkRecLen:]
+		id := binary.BigEndian.Uint32(rec)
+		start := binary.BigEndian.Uint64(rec[4:])
+		end := binary.BigEndian.Uint64(rec[graphChunkRecLen+4:])
+		if start > end || end > uint64(len(buf)) {
+			return nil, errors.New("invalid chunk offset")
+		}
+		chunk := buf[start:end]
+		switch id {
+		case graphChunkOIDF:
+			g.fanout = chunk
+		case graphChunkOIDL:
+			g.oids = chunk
+		case graphChunkCDAT:
+			g.data = chunk
+		case graphChunkEDGE:
+			g.edges = chunk
+		}
+	}
+	if len(g.fanout) != 256*4 || g.oids == nil || g.data == nil {
+		return nil, errors.New("missing required chunks")
+	}
+	g.n = binary.BigEndian.Uint32(g.fanout[255*4:])
+	if len(g.oids) < int(g.n)*g.hashLen || len(g.data) < int(g.n)*(g.hashLen+16) {
+		return nil, errors.New("truncated chunks")
+	}
+	return g, nil
+}
+
+// lookup returns the position of the commit in the graph.
+func (g *CommitGraph) lookup(sha1 string) (uint32, bool) {
+	oid, err := hex.DecodeString(sha1)
+	if err != nil || len(oid) != g.hashLen {
+		return 0, false
+	}
+	var lo uint32
+	if oid[0] > 0 {
+		lo = binary.BigEndian.Uint32(g.fanout[(int(oid[0])-1)*4:])
+	}
+	hi := binary.BigEndian.Uint32(g.fanout[int(oid[0])*4:])
+	i := sort.Search(int(hi-lo), func(i int) bool {
+		pos := int(lo) + i
+		return bytes.Compare(g.oids[pos*g.hashLen:(pos+1)*g.hashLen], oid) >= 0
+	})
+	pos := int(lo) + i
+	if pos < int(hi) && bytes.Equal(g.oids[pos*g.hashLen:(pos+1)*g.hashLen], oid) {
+		return uint32(pos), true
+	}
+	return 0, false
+}
+
+func (g *CommitGraph) record(pos uint32) []byte {
+	size := g.hashLen + 16
+	return g.data[int(pos)*size : int(pos+1)*size]
+}
+
+// generation returns the topological level of the commit, 0 when the
+// graph was written without generation numbers.
+func (g *CommitGraph) generation(pos uint32) uint32 {
+	return binary.BigEndian.Uint32(g.record(pos)[g.hashLen+8:]) >> 2
+}
+
+func (g *CommitGraph) parents(pos uint32) []uint32 {
+	rec := g.record(pos)
+	var parents []uint32
+	p1 := binary.BigEndian.Uint32(rec[g.hashLen:])
+	p2 := binary.BigEndian.Uint32(rec[g.hashLen+4:])
+	if p1 != graphParentNone {
+		parents = append(parents, p1)
+	}
+	if p2 == graphParentNone {
+		return parents
+	}
+	if p2&graphExtraEdges == 0 {
+		return append(parents, p2)
+	}
+	for i := int(p2 &^ graphExtraEdges); (i+1)*4 <= len(g.edges); i++ {
+		e := binary.BigEndian.Uint32(g.edges[i*4:])
+		parents = append(parents, e&^graphLastEdge)
+		if e&graphLastEdge != 0 {
+			break
+		}
+	}
+	return parents
+}
+
+// reachability answers whether commits can reach a fixed target commit,
+// remembering the answers across queries.
+type reachability struct {
+	g      *CommitGraph
+	target uint32
+	gen    uint32
+	memo   map[uint32]bool
+}
+
+func (g *CommitGraph) reaching(target uint32) *reachability {
+	return &reachability{g: g, target: target, gen: g.generation(target), memo: map[uint32]bool{}}
+}
+
+// from returns true if the target is reachable from the commit at pos. The
+// walk does not descend below the target's generation, since commits with
+// a lower or equal generation cannot reach it.
+func (r *reachability) from(pos uint32) bool {
+	type frame struct {
+		pos     uint32
+		parents []uint32
+		next    int
+	}
+	result := func(pos uint32) (bool, bool) {
+		if pos == r.target {
+			return true, true
+		}
+		if v, ok := r.memo[pos]; ok {
+			return v, true
+		}
+		if gen := r.g.generation(pos); r.gen != 0 && gen != 0 && gen <= r.gen {
+			return false, true
+		}
+		return false, false
+	}
+	if v, ok := result(pos); ok {
+		return v
+	}
+
+	stack := []*frame{{pos: pos, parents: r.g.parents(pos)}}
+	r.memo[pos] = false // guards against revisiting while on the stack
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		if f.next == len(f.parents) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		p := f.parents[f.next]
+		f.next++
+		if v, ok := result(p); ok {
+			if v {
+				// every commit on the stack reaches the target
+				for _, f := range stack {
+					r.memo[f.pos] = true
+				}
+				return true
+			}
+			continue
+		}
+		r.memo[p] = false
+		stack = append(stack, &frame{pos: p, parents: r.g.parents(p)})
+	}
+	return false
+}
+
+// isAncestor returns whether commit is an ancestor of, or the same as, ref.
+// ok is false when either commit is not in the graph.
+func (g *CommitGraph) isAncestor(commit, ref string) (contained, ok bool) {
+	c, ok := g.lookup(commit)
+	if !ok {
+		return false, false
+	}
+	r, ok := g.lookup(ref)
+	if !ok {
+		return false, false
+	}
+	return g.reaching(c).from(r), true
+}
+
+type tagRef struct {
+	name string
+	sha1 string
+}
+
+var (
+	tagRefs   = map[string][]tagRef{}
+	tagRefsMu sync.Mutex
+)
+
+// listTags returns the tags matching pattern and the commits they point
+// to, in version order.
+func listTags(pattern string) []tagRef {
+	tagRefsMu.Lock()
+	defer tagRefsMu.Unlock()
+	if tags, ok := tagRefs[pattern]; ok {
+		return tags
+	}
+	var tags []tagRef
+	for _, line := range linesFrom("git", "for-each-ref", "--sort=v:refname",
+		"--format=%(refname:short) %(objectname) %(*objectname)", "refs/tags/"+pattern) {
+		fields := strings.Fields(string(line))
+		switch len(fields) {
+		case 2:
+			tags = append(tags, tagRef{name: fields[0], sha1: fields[1]})
+		case 3:
+			// annotated tag, use the commit it points to
+			tags = append(tags, tagRef{name: fields[0], sha1: fields[2]})
+		}
+	}
+	tagRefs[pattern] = tags
+	return tags
+}
+
+// tagsContaining returns the tags matching pattern that contain the commit,
+// in version order. ok is false when a commit is not in the graph.
+func (g *CommitGraph) tagsContaining(sha1, pattern string) (tags []string, ok bool) {
+	c, ok := g.lookup(sha1)
+	if !ok {
+		return nil, false
+	}
+	r := g.reaching(c)
+	for _, tag := range listTags(pattern) {
+		pos, ok := g.lookup(tag.sha1)
+		if !ok {
+			return nil, false
+		}
+		if r.from(pos) {
+			tags = append(tags, tag.name)
+		}
+	}
+	return tags, true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitGraphIsAncestor(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	// A history with a merge and an octopus merge:
+	//
+	//   a - b - c ------- m - o
+	//        \  \        /   /|
+	//         \  d - e -    / |
+	//          f ----------   |
+	//          g -------------
+	git("init", "-q", "-b", "main")
+	commit := func(msg string) string {
+		git("commit", "-q", "--allow-empty", "-m", msg)
+		return git("rev-parse", "HEAD")
+	}
+	a := commit("a")
+	b := commit("b")
+	c := commit("c")
+	git("checkout", "-q", "-b", "side")
+	d := commit("d")
+	e := commit("e")
+	git("checkout", "-q", "-b", "f", b)
+	f := commit("f")
+	git("checkout", "-q", "-b", "g", b)
+	g := commit("g")
+	git("checkout", "-q", "main")
+	git("merge", "-q", "--no-ff", "-m", "m", "side")
+	m := git("rev-parse", "HEAD")
+	git("merge", "-q", "--no-ff", "-m", "o", "f", "g")
+	o := git("rev-parse", "HEAD")
+	git("commit-graph", "write", "--reachable")
+
+	graph, err := readCommitGraph(filepath.Join(dir, ".git", "objects", "info", "commit-graph"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{a: "a", b: "b", c: "c", d: "d", e: "e", f: "f", g: "g", m: "m", o: "o"}
+	for commit := range names {
+		for ref := range names {
+			want := exec.Command("git", "-C", dir, "merge-base", "--is-ancestor", commit, ref).Run() == nil
+			got, ok := graph.isAncestor(commit, ref)
+			if !ok {
+				t.Fatalf("%s or %s not found in the commit-graph", names[commit], names[ref])
+			}
+			if got != want {
+				t.Errorf("isAncestor(%s, %s) = %v, want %v", names[commit], names[ref], got, want)
+			}
+		}
+	}
+	if _, ok := graph.isAncestor(strings.Repeat("0", 40), o); ok {
+		t.Errorf("unknown commit found in the commit-graph")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// compareReports prints what changed since the previous -json report: the
+// files checked, their affected commits and their common tags.
+func compareReports(w io.Writer, name string, prev, cur jsonReport) {
+	fmt.Fprintf(w, "Changes since %s:\n", name)
+	old := map[string]jsonFile{}
+	for _, f := range prev.Files {
+		old[f.File] = f
+	}
+	changed := false
+	for _, f := range cur.Files {
+		o, ok := old[f.File]
+		delete(old, f.File)
+		var lines []string
+		if !ok {
+			lines = append(lines, "newly checked")
+		}
+		before := map[string]bool{}
+		for _, c := range o.Commits {
+			before[c.Commit] = true
+		}
+		for _, c := range f.Commits {
+			if !before[c.Commit] {
+				lines = append(lines, fmt.Sprintf("+ %s %s", shortSha1(c.Commit), getCommitSubject(c.Commit)))
+			}
+			delete(before, c.Commit)
+		}
+		for _, c := range o.Commits {
+			if before[c.Commit] {
+				lines = append(lines, fmt.Sprintf("- %s", shortSha1(c.Commit)))
+			}
+		}
+		if ok {
+			lines = append(lines, tagChanges(o.CommonTags, f.CommonTags)...)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		changed = true
+		fmt.Fprintf(w, "    %s\n", f.File)
+		for _, line := range lines {
+			fmt.Fprintf(w, "\t%s\n", line)
+		}
+	}
+	for _, f := range prev.Files {
+		if _, ok := old[f.File]; ok {
+			changed = true
+			fmt.Fprintf(w, "    %s\n\tno longer checked\n", f.File)
+		}
+	}
+	if lines := tagChanges(prev.CommonTags, cur.CommonTags); len(prev.Files) > 1 && len(cur.Files) > 1 && len(lines) > 0 {
+		changed = true
+		fmt.Fprintf(w, "    Summary\n")
+		for _, line := range lines {
+			fmt.Fprintf(w, "\t%s\n", line)
+		}
+	}
+	if !changed {
+		fmt.Fprintf(w, "    none\n")
+	}
+}
+
+// tagChanges tells the common tags gained and lost, and whether the oldest
+// common tag regressed: the change then needs a newer base than before.
+func tagChanges(old, cur []string) []string {
+	var lines []string
+	if gained := missingFrom(old, cur); len(gained) > 0 {
+		lines = append(lines, "tags gained: "+strings.Join(gained, " "))
+	}
+	if lost := missingFrom(cur, old); len(lost) > 0 {
+		lines = append(lines, "tags lost: "+strings.Join(lost, " "))
+	}
+	switch {
+	case len(old) > 0 && len(cur) == 0:
+		lines = append(lines, fmt.Sprintf("REGRESSED: no common tag, was %s", old[0]))
+	case len(old) > 0 && MergeBaseTags{old[0], cur[0]}.Less(0, 1):
+		lines = append(lines, fmt.Sprintf("REGRESSED: common tag %s, was %s", cur[0], old[0]))
+	}
+	return lines
+}
+
+// missingFrom returns the strings of b that are not in a.
+func missingFrom(a, b []string) []string {
+	in := map[string]bool{}
+	for _, s := range a {
+		in[s] = true
+	}
+	var missing []string
+	for _, s := range b {
+		if !in[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagChanges(t *testing.T) {
+	tests := []struct {
+		old, cur []string
+		want     []string
+	}{
+		{[]string{"MERGE_BASE_2", "MERGE_BASE_3"}, []string{"MERGE_BASE_2", "MERGE_BASE_3"}, nil},
+		{[]string{"MERGE_BASE_3"}, []string{"MERGE_BASE_2", "MERGE_BASE_3"},
+			[]string{"tags gained: MERGE_BASE_2"}},
+		{[]string{"MERGE_BASE_2", "MERGE_BASE_3"}, []string{"MERGE_BASE_3"},
+			[]string{"tags lost: MERGE_BASE_2", "REGRESSED: common tag MERGE_BASE_3, was MERGE_BASE_2"}},
+		{[]string{"MERGE_BASE_2"}, nil,
+			[]string{"tags lost: MERGE_BASE_2", "REGRESSED: no common tag, was MERGE_BASE_2"}},
+	}
+	for _, tt := range tests {
+		if got := tagChanges(tt.old, tt.cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tagChanges(%q, %q) = %q, want %q", tt.old, tt.cur, got, tt.want)
+		}
+	}
+}
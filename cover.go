@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// coverTags returns a small set of merge base tags that together contain
+// all the given commits, using the greedy set cover heuristic: the tag
+// containing the most not yet covered commits is picked first, the older
+// tag winning ties. Commits not contained in any tag are returned as
+// uncovered.
+func coverTags(commits map[string]MergeBaseTags) (cover MergeBaseTags, uncovered []string) {
+	tagCommits := map[string][]string{}
+	remaining := map[string]bool{}
+	for sha1, tags := range commits {
+		if len(tags) == 0 {
+			uncovered = append(uncovered, sha1)
+			continue
+		}
+		remaining[sha1] = true
+		for _, tag := range tags {
+			tagCommits[tag] = append(tagCommits[tag], sha1)
+		}
+	}
+	sort.Strings(uncovered)
+
+	var candidates MergeBaseTags
+	for tag := range tagCommits {
+		candidates = append(candidates, tag)
+	}
+	sort.Sort(candidates)
+
+	for len(remaining) > 0 {
+		best, bestCount := "", 0
+		for _, tag := range candidates {
+			count := 0
+			for _, sha1 := range tagCommits[tag] {
+				if remaining[sha1] {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = tag, count
+			}
+		}
+		cover = append(cover, best)
+		for _, sha1 := range tagCommits[best] {
+			delete(remaining, sha1)
+		}
+	}
+	sort.Sort(cover)
+	return cover, uncovered
+}
+
+// showTagCover prints the tags covering all the commits, for when there
+// is no common tag.
+func showTagCover(commits map[string]MergeBaseTags, indent string) {
+	cover, uncovered := coverTags(commits)
+	if len(cover) > 0 {
+		// MergeBaseTags.String truncates to -limit, the cover must be shown in full
+		fmt.Printf("%sMinimal tag cover: %s\n", indent, strings.Join(cover, " "))
+	}
+	if len(uncovered) > 0 {
+		var short []string
+		for _, sha1 := range uncovered {
+			short = append(short, shortSha1(sha1))
+		}
+		fmt.Printf("%sNot in any tag: %s\n", indent, strings.Join(short, " "))
+	}
+}
+
+// backportBranches returns the branches a fix must be merged into so that,
+// merging upward from older to newer releases, it reaches every branch
+// containing the affected commits: the oldest branch containing each
+// commit, leaving out the newer branches of a remote that the merge up
+// from an older one already reaches.
+func backportBranches(branches map[string][]string) []string {
+	var oldest []string
+	seen := map[string]bool{}
+	for _, containing := range branches {
+		if len(containing) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), containing...)
+		sortBranches(sorted)
+		if b := sorted[0]; !seen[b] {
+			seen[b] = true
+			oldest = append(oldest, b)
+		}
+	}
+	sortBranches(oldest)
+	var result []string
+	reached := map[string]bool{}
+	for _, b := range oldest {
+		if remote := b[:strings.LastIndex(b, "/")+1]; !reached[remote] {
+			reached[remote] = true
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+func showBackportBranches(branches map[string][]string, indent string) {
+	if backport := backportBranches(branches); len(backport) > 0 {
+		fmt.Printf("%sBackport to: %s (then merge upward)\n", indent, strings.Join(backport, " "))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverTags(t *testing.T) {
+	tests := []struct {
+		commits       map[string]MergeBaseTags
+		wantCover     MergeBaseTags
+		wantUncovered []string
+	}{
+		{
+			commits: map[string]MergeBaseTags{
+				"a": {"MERGE_BASE_1", "MERGE_BASE_2"},
+				"b": {"MERGE_BASE_2", "MERGE_BASE_3"},
+				"c": {"MERGE_BASE_3"},
+			},
+			wantCover: MergeBaseTags{"MERGE_BASE_2", "MERGE_BASE_3"},
+		},
+		{
+			commits: map[string]MergeBaseTags{
+				"a": {"MERGE_BASE_10", "MERGE_BASE_11"},
+				"b": {"MERGE_BASE_10", "MERGE_BASE_11"},
+				"c": nil,
+			},
+			wantCover:     MergeBaseTags{"MERGE_BASE_10"},
+			wantUncovered: []string{"c"},
+		},
+		{
+			commits: map[string]MergeBaseTags{
+				"a": nil,
+			},
+			wantUncovered: []string{"a"},
+		},
+	}
+
+	for i, tt := range tests {
+		cover, uncovered := coverTags(tt.commits)
+		if !reflect.DeepEqual(cover, tt.wantCover) || !reflect.DeepEqual(uncovered, tt.wantUncovered) {
+			t.Errorf("tests[%d] failed\nwant: %q %q\n got: %q %q", i, tt.wantCover, tt.wantUncovered, cover, uncovered)
+		}
+	}
+}
+
+func TestBackportBranches(t *testing.T) {
+	got := backportBranches(map[string][]string{
+		"a": {"origin/develop", "origin/release-10", "origin/release-9"},
+		"b": {"origin/develop", "origin/release-10"},
+		"c": {"origin/develop", "origin/release-9", "origin/release-10"},
+		"d": {"origin/develop"},
+		"e": nil,
+		"f": {"upstream/develop", "upstream/release-10"},
+	})
+	want := []string{"origin/release-9", "upstream/release-10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %q\n got: %q", want, got)
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"MERGE_BASE_9", "MERGE_BASE_10", true},
+		{"MERGE_BASE_10", "MERGE_BASE_9", false},
+		{"v1.9", "v1.10", true},
+		{"v1.1", "v1.1.1", true},
+		{"v1.01", "v1.1", false},
+		{"SVC_MERGE_BASE_2", "MERGE_BASE_1", false},
+	}
+	for _, tt := range tests {
+		if got := versionLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("versionLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// dateFormats are the presets of -date-format.
+var dateFormats = map[string]string{
+	"iso": "2006-01-02 15:04:05 -0700",
+	"rfc": time.RFC1123Z,
+}
+
+// checkDateFormat fails unless the -date-format layout shows something of
+// the date, as a misspelled preset would not.
+func checkDateFormat(layout string) {
+	if layout == "" || layout == "unix" || dateFormats[layout] != "" {
+		return
+	}
+	if time.Unix(0, 0).UTC().Format(layout) == layout {
+		bail("error: -date-format %s: not a preset (iso, rfc, unix) nor a Go time layout", layout)
+	}
+}
+
+// formatDate renders a commit date as set by -date-format, the time.Time
+// default when unset.
+func formatDate(t time.Time, layout string) string {
+	switch {
+	case layout == "":
+		return t.String()
+	case layout == "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case dateFormats[layout] != "":
+		layout = dateFormats[layout]
+	}
+	return t.Format(layout)
+}
+
+func showCommitDate(sha1 string) {
+	fmt.Printf(" %s", formatDate(getCommitDate(sha1), optDateFormat))
+}
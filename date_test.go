@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 8*3600))
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{"iso", "2021-03-04 05:06:07 +0800"},
+		{"rfc", "Thu, 04 Mar 2021 05:06:07 +0800"},
+		{"unix", "1614805567"},
+		{"2006-01-02", "2021-03-04"},
+		{"", "2021-03-04 05:06:07 +0800 +0800"},
+	}
+	for _, tt := range tests {
+		if got := formatDate(date, tt.layout); got != tt.want {
+			t.Errorf("formatDate(%v, %q) = %q, want %q", date, tt.layout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Distance is how far an affected commit is from HEAD.
+type Distance struct {
+	// Commits of HEAD that the commit does not have
+	Commits int
+	// Time between the commit and HEAD
+	Age time.Duration
+	// The commit is not an ancestor of HEAD, Commits and Age are unset
+	OffBranch bool
+}
+
+var distanceCache cache[Distance]
+
+// distanceFromHead returns how many commits and how much time separate the
+// commit from HEAD, counted only when the commit is on the current branch.
+func distanceFromHead(sha1 string) Distance {
+	return distanceCache.get(sha1, func() Distance {
+		if !isAncestor(sha1, "HEAD") {
+			return Distance{OffBranch: true}
+		}
+		count := string(linesFrom("git", "rev-list", "--count", sha1+"..HEAD")[0])
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			bail("error: cannot count the commits from %s to HEAD: %v", sha1, err)
+		}
+		return Distance{Commits: n, Age: getCommitDate("HEAD").Sub(getCommitDate(sha1))}
+	})
+}
+
+// distancesFromHead returns the distance of each commit from HEAD, counting
+// each distinct commit once.
+func distancesFromHead(sha1s []string) map[string]Distance {
+	distances := map[string]Distance{}
+	for _, sha1 := range sha1s {
+		if _, ok := distances[sha1]; !ok {
+			distances[sha1] = distanceFromHead(sha1)
+		}
+	}
+	return distances
+}
+
+func (d Distance) String() string {
+	if d.OffBranch {
+		return "not on the current branch"
+	}
+	days := int(d.Age.Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	return fmt.Sprintf("%d commits, %d days before HEAD", d.Commits, days)
+}
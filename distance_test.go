@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDistanceString(t *testing.T) {
+	tests := []struct {
+		d    Distance
+		want string
+	}{
+		{Distance{Commits: 3, Age: 50 * time.Hour}, "3 commits, 2 days before HEAD"},
+		{Distance{Commits: 1, Age: -time.Hour}, "1 commits, 0 days before HEAD"},
+		{Distance{OffBranch: true}, "not on the current branch"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
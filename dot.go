@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// writeDot writes a Graphviz graph of the changed files, the commits they
+// affect, and the merge base tags and branches containing those commits.
+func writeDot(filename string, reports []*FileReport) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "digraph checkdiff {\n")
+	fmt.Fprintf(w, "\trankdir=LR;\n")
+
+	nodes := map[string]bool{}
+	node := func(id, label, shape string) {
+		if nodes[id] {
+			return
+		}
+		nodes[id] = true
+		fmt.Fprintf(w, "\t%q [label=%q, shape=%s];\n", id, label, shape)
+	}
+	edges := map[string]bool{}
+	edge := func(from, to string) {
+		e := from + "\x00" + to
+		if edges[e] {
+			return
+		}
+		edges[e] = true
+		fmt.Fprintf(w, "\t%q -> %q;\n", from, to)
+	}
+
+	for _, r := range reports {
+		fileID := "file:" + r.File
+		node(fileID, r.File, "note")
+
+		var commits []string
+		for sha1 := range r.Commits {
+			commits = append(commits, sha1)
+		}
+		sort.Strings(commits)
+		for _, sha1 := range commits {
+			commitID := "commit:" + sha1
+			node(commitID, shortSha1(sha1), "box")
+			edge(fileID, commitID)
+			for _, tag := range r.Commits[sha1] {
+				tagID := "tag:" + tag
+				node(tagID, tag, "ellipse")
+				edge(commitID, tagID)
+			}
+			for _, branch := range r.Branches[sha1] {
+				branchID := "branch:" + branch
+				node(branchID, branch, "hexagon")
+				edge(commitID, branchID)
+			}
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+const envPrefix = "GIT_CHECK_DIFF_"
+
+// envName returns the environment variable setting the default of the
+// flag: GIT_CHECK_DIFF_MAX_PROCS for -max-procs.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// envFlags are the names of the flags set from the environment.
+var envFlags = map[string]bool{}
+
+// setFlagsFromEnv sets the flags from their GIT_CHECK_DIFF_* environment
+// variables, before the command line overrides them. The boolean ones take
+// true or false, 1 or 0.
+func setFlagsFromEnv() {
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			bail("error: %s=%s: %v", name, value, err)
+		}
+		envFlags[f.Name] = true
+	})
+}
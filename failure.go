@@ -0,0 +1,24 @@
+package main
+
+// failure is the error bail reports, instead of exiting, while failures are
+// soft: a file that cannot be checked then does not end the run.
+type failure string
+
+func (f failure) Error() string { return string(f) }
+
+// set while the files of a multi-file run are checked
+var softFailures int32
+
+// catchFailure calls f, returning the failure it bails with as an error.
+func catchFailure(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	return f()
+}
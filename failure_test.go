@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCatchFailure(t *testing.T) {
+	atomic.StoreInt32(&softFailures, 1)
+	defer atomic.StoreInt32(&softFailures, 0)
+
+	err := catchFailure(func() error {
+		bail("error: %s is binary", "b.bin")
+		return nil
+	})
+	if err == nil || err.Error() != "error: b.bin is binary" {
+		t.Errorf("catchFailure of bail = %v, want the bail message", err)
+	}
+
+	want := errors.New("failed")
+	if err := catchFailure(func() error { return want }); err != want {
+		t.Errorf("catchFailure = %v, want %v", err, want)
+	}
+
+	var g group
+	g.Go(func() error { return nil })
+	g.Go(func() error {
+		bail("error: blame failed")
+		return nil
+	})
+	if err := g.Wait(); err == nil || err.Error() != "error: blame failed" {
+		t.Errorf("group.Wait = %v, want the bail message", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// funcnames are the patterns of the lines naming the function, or class,
+// that holds a hunk, after the builtin diff drivers of git. git uses them
+// only when .gitattributes sets the driver of the file, otherwise it takes
+// the last line starting with a letter, which misses indented methods.
+var funcnames = map[string]*regexp.Regexp{
+	".go":   regexp.MustCompile(`^(func[ \t].*|type[ \t].*)$`),
+	".c":    regexp.MustCompile(`^[A-Za-z_][A-Za-z_0-9 \t*:<>,]*\(.*$`),
+	".java": regexp.MustCompile(`^[ \t]*(((public|protected|private|static|abstract|final|synchronized)[ \t]+)*[A-Za-z_][A-Za-z_0-9<>\[\], ]*[ \t]+[A-Za-z_][A-Za-z_0-9]*[ \t]*\([^;]*|.*\b(class|interface|enum)[ \t].*)$`),
+	".js":   regexp.MustCompile(`^[ \t]*(.*\bfunction\b.*|(export[ \t]+)?(default[ \t]+)?class[ \t].*|(async[ \t]+)?[A-Za-z_$][A-Za-z_0-9$]*[ \t]*\([^;]*\)[ \t]*\{[ \t]*)$`),
+	".py":   regexp.MustCompile(`^[ \t]*((class|(async[ \t]+)?def)[ \t].*)$`),
+	".rb":   regexp.MustCompile(`^[ \t]*((class|module|def)[ \t].*)$`),
+	".rs":   regexp.MustCompile(`^[ \t]*((pub(\([^)]*\))?[ \t]+)?((async|const|unsafe|extern)[ \t]+)*(fn|struct|enum|impl|trait|mod)[ \t].*)$`),
+	".sh":   regexp.MustCompile(`^[ \t]*((function[ \t]+)?[A-Za-z_][A-Za-z_0-9]*[ \t]*\(\)[ \t]*\{?.*|function[ \t]+[A-Za-z_][A-Za-z_0-9]*.*)$`),
+}
+
+func init() {
+	for _, ext := range []string{".h", ".cc", ".cpp", ".hpp", ".cxx"} {
+		funcnames[ext] = funcnames[".c"]
+	}
+	for _, ext := range []string{".ts", ".jsx", ".tsx", ".mjs"} {
+		funcnames[ext] = funcnames[".js"]
+	}
+	funcnames[".bash"] = funcnames[".sh"]
+	funcnames[".kt"] = funcnames[".java"]
+	funcnames[".scala"] = funcnames[".java"]
+}
+
+// addFuncnames sets the name of the function holding each hunk in the hunk
+// headers, looking for it in the old content of the file. The names git
+// gives are kept when .gitattributes sets a diff driver for the file, or
+// when no function is found.
+func addFuncnames(file, rev string, hunks unidiff.Hunks) {
+	pattern := funcnames[strings.ToLower(filepath.Ext(file))]
+	if pattern == nil || hasDiffDriver(file) {
+		return
+	}
+	var lines []string
+	for _, hunk := range hunks {
+		if len(hunk.Lines) == 0 {
+			continue
+		}
+		if lines == nil {
+			lines = strings.Split(blobContent(rev, file), "\n")
+		}
+		// the lines before the hunk, the start is the line before an
+		// empty range
+		lnum := hunk.Removed.Start - 1
+		if hunk.Removed.Count == 0 {
+			lnum = hunk.Removed.Start
+		}
+		if lnum > len(lines) {
+			lnum = len(lines)
+		}
+		for ; lnum >= 1; lnum-- {
+			line := strings.TrimRight(lines[lnum-1], "\r")
+			if pattern.MatchString(line) {
+				header := append([]byte(nil), headerRanges(hunk.Lines[0])...)
+				hunk.Lines[0] = append(append(header, ' '), strings.TrimSpace(line)...)
+				break
+			}
+		}
+	}
+}
+
+// headerRanges returns the hunk header up to its closing @@, without the
+// function name.
+func headerRanges(header []byte) []byte {
+	marker := header[:bytes.IndexByte(header, ' ')]
+	i := bytes.Index(header[len(marker):], marker)
+	if i < 0 {
+		return header
+	}
+	return header[:len(marker)+i+len(marker)]
+}
+
+// hasDiffDriver tells whether .gitattributes sets the diff driver of the
+// file, whose funcname patterns git uses.
+func hasDiffDriver(file string) bool {
+	out, err := gitOutput("check-attr", "diff", "--", file)
+	if err != nil {
+		return false
+	}
+	value := strings.TrimSpace(string(out[bytes.LastIndex(out, []byte(": "))+2:]))
+	return value != "unspecified" && value != "unset" && value != "set"
+}
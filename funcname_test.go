@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestHeaderRanges(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"@@ -6 +6 @@", "@@ -6 +6 @@"},
+		{"@@ -3,6 +3,6 @@ class A {", "@@ -3,6 +3,6 @@"},
+		{"@@@ -1,3 -1,4 +1,10 @@@ func f() {", "@@@ -1,3 -1,4 +1,10 @@@"},
+	}
+	for _, tt := range tests {
+		if got := string(headerRanges([]byte(tt.header))); got != tt.want {
+			t.Errorf("headerRanges(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFuncnames(t *testing.T) {
+	tests := []struct {
+		ext, line string
+		want      bool
+	}{
+		{".go", "func (r *FileReport) sortedCommits() []string {", true},
+		{".go", "\treturn nil", false},
+		{".java", "    public int f(int x) {", true},
+		{".java", "        return x;", false},
+		{".py", "    def f(self):", true},
+		{".py", "        x = f(1)", false},
+		{".c", "static int main(int argc, char **argv)", true},
+		{".sh", "usage() {", true},
+	}
+	for _, tt := range tests {
+		if got := funcnames[tt.ext].MatchString(tt.line); got != tt.want {
+			t.Errorf("funcnames[%q].MatchString(%q) = %v, want %v", tt.ext, tt.line, got, tt.want)
+		}
+	}
+}
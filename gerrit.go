@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// changePatchset is the patchset of -change that is checked.
+var changePatchset int
+
+// parseChange parses a Gerrit change given as number or number/patchset,
+// patchset being 0 when left out.
+func parseChange(change string) (number, patchset int, err error) {
+	parts := strings.SplitN(change, "/", 2)
+	number, err = strconv.Atoi(parts[0])
+	if err != nil || number <= 0 {
+		return 0, 0, fmt.Errorf("-change %s: not a change number", change)
+	}
+	if len(parts) == 2 {
+		patchset, err = strconv.Atoi(parts[1])
+		if err != nil || patchset <= 0 {
+			return 0, 0, fmt.Errorf("-change %s: not a patchset number", change)
+		}
+	}
+	return number, patchset, nil
+}
+
+// changeRef returns the refs/changes/ ref of a patchset, "*" for all of
+// them.
+func changeRef(number int, patchset string) string {
+	return fmt.Sprintf("refs/changes/%02d/%d/%s", number%100, number, patchset)
+}
+
+// fetchChange fetches the patchset of the Gerrit change, the latest one
+// when none is given, from the first -remote and returns the commit.
+func fetchChange(change string) string {
+	number, patchset, err := parseChange(change)
+	if err != nil {
+		bail("error: %v", err)
+	}
+	if patchset == 0 {
+		out, err := gitOutput("ls-remote", remotes[0], changeRef(number, "*"))
+		if err != nil {
+			bail("error: cannot list the patchsets of change %d on %s", number, remotes[0])
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			n, err := strconv.Atoi(fields[1][strings.LastIndex(fields[1], "/")+1:])
+			if err == nil && n > patchset {
+				patchset = n
+			}
+		}
+		if patchset == 0 {
+			bail("error: change %d not found on %s", number, remotes[0])
+		}
+	}
+	changePatchset = patchset
+	return fetchReviewRef(remotes[0], changeRef(number, strconv.Itoa(patchset)),
+		fmt.Sprintf("changes/%d/%d", number, patchset))
+}
+
+// postChangeReview posts the plain text report as a review comment on the
+// checked patchset of the Gerrit change, voting on -gerrit-label when given
+// according to ok. The REST API is served from the host of the first
+// -remote unless GIT_CHECK_DIFF_GERRIT_URL gives the URL of the server.
+func postChangeReview(change, user, password string, reports []*FileReport, commonTags MergeBaseTags, ok bool) error {
+	number, _, err := parseChange(change)
+	if err != nil {
+		return err
+	}
+	base := os.Getenv("GIT_CHECK_DIFF_GERRIT_URL")
+	if base == "" {
+		host, _ := remoteHostPath(remotes[0])
+		if host == "" {
+			return fmt.Errorf("cannot tell the Gerrit server of remote %s", remotes[0])
+		}
+		base = "https://" + host
+	}
+	api := fmt.Sprintf("%s/a/changes/%d/revisions/%d/review", strings.TrimSuffix(base, "/"), number, changePatchset)
+
+	review := map[string]interface{}{
+		"message": "git check-diff:\n\n" + indent(reportText(reports, commonTags)),
+	}
+	if optGerritLabel != "" {
+		vote := -1
+		if ok {
+			vote = 1
+		}
+		review["labels"] = map[string]int{optGerritLabel: vote}
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", api, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(user, password)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", api, resp.Status)
+	}
+	return nil
+}
+
+// indent makes preformatted text of every line of s, for Gerrit comments.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "  " + line
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
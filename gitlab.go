@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// fetchMergeRequest fetches the head of the GitLab merge request iid from
+// the first -remote and returns the commit and the one of the branch it
+// targets, see mergeTarget.
+func fetchMergeRequest(iid string) (head, target string) {
+	if n, err := strconv.Atoi(iid); err != nil || n <= 0 {
+		bail("error: -mr %s: not a merge request number", iid)
+	}
+	head = fetchReviewRef(remotes[0], "refs/merge-requests/"+iid+"/head", "merge-requests/"+iid)
+	return head, mergeTarget(remotes[0], "refs/merge-requests/"+iid+"/merge", "merge-requests/"+iid+"-merge")
+}
+
+// postMergeRequestNote posts the plain text report as a note of the GitLab
+// merge request iid of the project the first -remote fetches from,
+// authenticating with token. The API is served from the host of the remote
+// unless GIT_CHECK_DIFF_GITLAB_URL gives the URL of the GitLab server.
+func postMergeRequestNote(iid, token string, reports []*FileReport, commonTags MergeBaseTags) error {
+	host, path := remoteHostPath(remotes[0])
+	if host == "" {
+		return fmt.Errorf("cannot tell the GitLab project of remote %s", remotes[0])
+	}
+	api := fmt.Sprintf("https://%s/api/v4/projects/%s/merge_requests/%s/notes",
+		host, url.PathEscape(path), iid)
+	if base := os.Getenv("GIT_CHECK_DIFF_GITLAB_URL"); base != "" {
+		api = fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%s/notes",
+			base, url.PathEscape(path), iid)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"body": "git check-diff:\n```\n" + reportText(reports, commonTags) + "```\n",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", api, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("PRIVATE-TOKEN", token)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", api, resp.Status)
+	}
+	return nil
+}
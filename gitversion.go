@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gitVersion is the major, minor and patch version of git.
+type gitVersion [3]int
+
+func (v gitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+func (v gitVersion) atLeast(w gitVersion) bool {
+	for i := range v {
+		if v[i] != w[i] {
+			return v[i] > w[i]
+		}
+	}
+	return true
+}
+
+var (
+	// git describe --exclude
+	minGitVersion = gitVersion{2, 13, 0}
+	// git blame --ignore-rev
+	ignoreRevGitVersion = gitVersion{2, 23, 0}
+	// git fetch --no-write-fetch-head
+	noFetchHeadGitVersion = gitVersion{2, 29, 0}
+	// GIT_CONFIG_COUNT
+	configEnvGitVersion = gitVersion{2, 31, 0}
+
+	installedGit gitVersion
+	noIgnoreRev  sync.Once
+)
+
+// parseGitVersion parses the output of git version, such as "git version
+// 2.39.2" or "git version 2.30.1 (Apple Git-130)".
+func parseGitVersion(out string) (gitVersion, bool) {
+	var v gitVersion
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return v, false
+	}
+	numbers := strings.SplitN(fields[2], ".", 4)
+	if len(numbers) < 2 {
+		return v, false
+	}
+	for i, n := range numbers {
+		if i == len(v) {
+			break
+		}
+		num, err := strconv.Atoi(n)
+		if err != nil {
+			if i < 2 {
+				return v, false
+			}
+			break
+		}
+		v[i] = num
+	}
+	return v, true
+}
+
+// checkGitVersion finds out the version of git, failing with a clear error
+// when git is missing or too old.
+func checkGitVersion() {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		bail("error: cannot run git: %v", err)
+	}
+	v, ok := parseGitVersion(string(out))
+	if !ok {
+		bail("error: unexpected git version output: %s", strings.TrimSpace(string(out)))
+	}
+	if !v.atLeast(minGitVersion) {
+		bail("error: git %s is too old, git check-diff needs git %s or later", v, minGitVersion)
+	}
+	installedGit = v
+}
+
+// canIgnoreRevs tells whether git blame can look through commits, warning
+// once when it cannot.
+func canIgnoreRevs() bool {
+	if installedGit == (gitVersion{}) || installedGit.atLeast(ignoreRevGitVersion) {
+		return true
+	}
+	noIgnoreRev.Do(func() {
+		warn("warning: git %s cannot ignore commits in blame (needs git %s), they are blamed as any other",
+			installedGit, ignoreRevGitVersion)
+	})
+	return false
+}
+
+// fetchArgs returns the arguments of git fetch for the temporary refs of
+// -pr, -mr, -change and bundles: quiet, without tags and, when git can,
+// leaving FETCH_HEAD alone.
+func fetchArgs(arg ...string) []string {
+	args := []string{"fetch", "-q", "--no-tags"}
+	if installedGit == (gitVersion{}) || installedGit.atLeast(noFetchHeadGitVersion) {
+		args = append(args, "--no-write-fetch-head")
+	}
+	return append(args, arg...)
+}
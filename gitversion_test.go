@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		out  string
+		want gitVersion
+		ok   bool
+	}{
+		{"git version 2.39.2\n", gitVersion{2, 39, 2}, true},
+		{"git version 2.30.1 (Apple Git-130)\n", gitVersion{2, 30, 1}, true},
+		{"git version 2.41.0.windows.1\n", gitVersion{2, 41, 0}, true},
+		{"git version 2.45.rc0\n", gitVersion{2, 45, 0}, true},
+		{"git version 2\n", gitVersion{}, false},
+		{"hub version 2.14.2\n", gitVersion{}, false},
+		{"", gitVersion{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGitVersion(tt.out)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseGitVersion(%q) = %v, %v, want %v, %v", tt.out, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGitVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		v, w gitVersion
+		want bool
+	}{
+		{gitVersion{2, 23, 0}, gitVersion{2, 23, 0}, true},
+		{gitVersion{2, 23, 1}, gitVersion{2, 23, 0}, true},
+		{gitVersion{2, 9, 5}, gitVersion{2, 13, 0}, false},
+		{gitVersion{3, 0, 0}, gitVersion{2, 31, 0}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.v.atLeast(tt.w); got != tt.want {
+			t.Errorf("%v.atLeast(%v) = %v, want %v", tt.v, tt.w, got, tt.want)
+		}
+	}
+}
+
+func TestFetchArgs(t *testing.T) {
+	defer func(v gitVersion) { installedGit = v }(installedGit)
+	tests := []struct {
+		git  gitVersion
+		want string
+	}{
+		{gitVersion{2, 28, 0}, "fetch -q --no-tags origin"},
+		{gitVersion{2, 29, 0}, "fetch -q --no-tags --no-write-fetch-head origin"},
+	}
+	for _, tt := range tests {
+		installedGit = tt.git
+		if got := strings.Join(fetchArgs("origin"), " "); got != tt.want {
+			t.Errorf("git %v: fetchArgs = %q, want %q", tt.git, got, tt.want)
+		}
+	}
+}
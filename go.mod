@@ -0,0 +1,3 @@
+module github.com/holygeek/git-check-diff
+
+go 1.21
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"sync"
+)
+
+// group runs functions concurrently and returns the first error they
+// return once all are done, like golang.org/x/sync/errgroup.Group. A soft
+// failure of a function is its error.
+type group struct {
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := catchFailure(f); err != nil {
+			g.once.Do(func() { g.err = err })
+		}
+	}()
+}
+
+func (g *group) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorCyan    = "\x1b[36m"
+	colorKeyword = "\x1b[1;34m"
+	colorString  = "\x1b[33m"
+	colorComment = "\x1b[90m"
+	colorNumber  = "\x1b[35m"
+)
+
+// language is what the highlighting of the hunks knows of a programming
+// language: its keywords and comment markers.
+type language struct {
+	keywords []string
+	comment  string
+	tokens   *regexp.Regexp
+}
+
+var languages = map[string]*language{
+	".go": {comment: "//", keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+		"range", "return", "select", "struct", "switch", "type", "var", "nil", "true", "false"}},
+	".c": {comment: "//", keywords: []string{"break", "case", "char", "const", "continue", "default", "do",
+		"double", "else", "enum", "extern", "float", "for", "goto", "if", "int", "long", "return", "short",
+		"signed", "sizeof", "static", "struct", "switch", "typedef", "union", "unsigned", "void", "while",
+		"NULL", "class", "namespace", "public", "private", "protected", "template", "new", "delete"}},
+	".java": {comment: "//", keywords: []string{"abstract", "break", "case", "catch", "class", "continue",
+		"default", "do", "else", "extends", "final", "finally", "for", "if", "implements", "import",
+		"interface", "new", "null", "package", "private", "protected", "public", "return", "static",
+		"super", "switch", "this", "throw", "throws", "try", "void", "while", "true", "false"}},
+	".js": {comment: "//", keywords: []string{"async", "await", "break", "case", "catch", "class", "const",
+		"continue", "default", "else", "export", "extends", "false", "finally", "for", "function", "if",
+		"import", "let", "new", "null", "return", "switch", "this", "throw", "true", "try", "typeof",
+		"undefined", "var", "while", "interface", "type"}},
+	".py": {comment: "#", keywords: []string{"and", "as", "assert", "async", "await", "break", "class",
+		"continue", "def", "del", "elif", "else", "except", "False", "finally", "for", "from", "if",
+		"import", "in", "is", "lambda", "None", "not", "or", "pass", "raise", "return", "True", "try",
+		"while", "with", "yield"}},
+	".sh": {comment: "#", keywords: []string{"case", "do", "done", "elif", "else", "esac", "fi", "for",
+		"function", "if", "in", "local", "return", "then", "while"}},
+	".rs": {comment: "//", keywords: []string{"as", "break", "const", "continue", "crate", "else", "enum",
+		"false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub",
+		"ref", "return", "self", "Self", "static", "struct", "trait", "true", "type", "use", "where",
+		"while"}},
+	".rb": {comment: "#", keywords: []string{"begin", "class", "def", "do", "else", "elsif", "end",
+		"ensure", "false", "if", "module", "nil", "require", "rescue", "return", "self", "true",
+		"unless", "until", "when", "while", "yield"}},
+}
+
+func init() {
+	for _, ext := range []string{".h", ".cc", ".cpp", ".hpp", ".cxx"} {
+		languages[ext] = languages[".c"]
+	}
+	for _, ext := range []string{".ts", ".jsx", ".tsx", ".mjs"} {
+		languages[ext] = languages[".js"]
+	}
+	languages[".bash"] = languages[".sh"]
+	languages[".kt"] = languages[".java"]
+	languages[".scala"] = languages[".java"]
+	for _, l := range languages {
+		if l.tokens == nil {
+			l.tokens = tokenRegexp(l)
+		}
+	}
+}
+
+// tokenRegexp returns the regexp matching the tokens of the language that
+// are highlighted, in this order: comment, string, keyword and number.
+func tokenRegexp(l *language) *regexp.Regexp {
+	return regexp.MustCompile(`(` + regexp.QuoteMeta(l.comment) + `.*$)` +
+		`|("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`" + `)` +
+		`|\b(` + strings.Join(l.keywords, "|") + `)\b` +
+		`|\b([0-9][0-9a-fA-FxX._]*)\b`)
+}
+
+// colorOutput tells whether the output is a terminal that colors are shown
+// on.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// showHunks prints the hunks of the file, highlighted on a terminal.
+func showHunks(file string, hunks unidiff.Hunks) {
+	if !colorOutput() {
+		for _, hunk := range hunks {
+			fmt.Printf("%s\n", hunk.Lines)
+		}
+		return
+	}
+	lang := languages[strings.ToLower(filepath.Ext(file))]
+	for _, hunk := range hunks {
+		n := len(hunk.Parents)
+		for i, line := range hunk.Lines {
+			if i == 0 {
+				fmt.Printf("%s%s%s\n", colorCyan, line, colorReset)
+				continue
+			}
+			if len(line) < n || line[0] == '\\' {
+				fmt.Printf("%s\n", line)
+				continue
+			}
+			columns, content := line[:n], line[n:]
+			marker := ""
+			switch {
+			case bytes.IndexByte(columns, '-') >= 0:
+				marker = colorRed
+			case bytes.IndexByte(columns, '+') >= 0:
+				marker = colorGreen
+			}
+			if marker != "" {
+				fmt.Printf("%s%s%s", marker, columns, colorReset)
+			} else {
+				fmt.Printf("%s", columns)
+			}
+			fmt.Printf("%s\n", highlight(lang, content))
+		}
+	}
+}
+
+// highlight colors the tokens of the line, which is left as is for the
+// languages it does not know.
+func highlight(l *language, line []byte) []byte {
+	if l == nil {
+		return line
+	}
+	colors := []string{colorComment, colorString, colorKeyword, colorNumber}
+	return l.tokens.ReplaceAllFunc(line, func(token []byte) []byte {
+		m := l.tokens.FindSubmatchIndex(token)
+		for i, color := range colors {
+			if m[2*(i+1)] >= 0 {
+				return []byte(color + string(token) + colorReset)
+			}
+		}
+		return token
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// LineHistory is the last commits that touched a range of changed lines,
+// as shown by git log -L.
+type LineHistory struct {
+	Start, End int
+	// Abbreviated id, date and subject of each commit, the newest first
+	Commits []string
+}
+
+// lineHistory returns the last n commits of the lines each hunk of the
+// diff changes in rev, where the file has the given number of lines.
+func lineHistory(file, rev string, diff unidiff.Diff, lines, n int) []LineHistory {
+	var history []LineHistory
+	for _, hunk := range diff.Hunks {
+		start, end := changedRange(hunk)
+		if start == 0 || start > lines {
+			continue
+		}
+		if end > lines {
+			end = lines
+		}
+		h := LineHistory{Start: start, End: end}
+		for _, line := range linesFrom("git", "log", "-L", fmt.Sprintf("%d,%d:%s", start, end, file), "-s",
+			"--date=short", "--format=%h %ad %s", "-n", strconv.Itoa(n), rev) {
+			if len(line) > 0 {
+				h.Commits = append(h.Commits, string(line))
+			}
+		}
+		history = append(history, h)
+	}
+	return history
+}
+
+// changedRange returns the first and last lines of the old file, or of the
+// first parent, that the hunk changes, 0 for none. Lines added at the
+// beginning of the file change the first line.
+func changedRange(hunk *unidiff.HunkPair) (start, end int) {
+	parent := hunk.Parents[0]
+	for _, lnums := range [][]int{parent.Removed, parent.Inserted} {
+		for _, lnum := range lnums {
+			if lnum < 1 {
+				lnum = 1
+			}
+			if start == 0 || lnum < start {
+				start = lnum
+			}
+			if lnum > end {
+				end = lnum
+			}
+		}
+	}
+	return start, end
+}
+
+func showHistory(history []LineHistory) {
+	for _, h := range history {
+		if h.Start == h.End {
+			fmt.Printf("    History of line %d:\n", h.Start)
+		} else {
+			fmt.Printf("    History of lines %d-%d:\n", h.Start, h.End)
+		}
+		for _, c := range h.Commits {
+			fmt.Printf("\t%s\n", c)
+		}
+	}
+}
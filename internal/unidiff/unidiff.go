@@ -0,0 +1,423 @@
+// Package unidiff parses the unified diffs produced by git diff.
+package unidiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	space = []byte{' '}
+	comma = []byte{','}
+)
+
+// Hunk is the range of lines of one side of a hunk.
+type Hunk struct {
+	Start int
+	Count int
+}
+
+type Lines [][]byte
+
+func (l Lines) String() string {
+	return fmt.Sprintf("%s", bytes.Join(l, []byte{'\n'}))
+}
+
+// Parent tells the lines of a hunk that changed relative to the old file,
+// or to one parent of a combined diff as produced for the conflicted files
+// of a merge.
+type Parent struct {
+	Hunk
+	// Lines of the parent that are not in the result
+	Removed []int
+	// Lines of the parent after which lines missing from the parent were
+	// added, 0 for the beginning of the file
+	Inserted []int
+}
+
+// HunkPair is a hunk of the diff: the lines removed from the old file and
+// the lines added to the new one. For combined diffs Removed is the range
+// of the first parent.
+type HunkPair struct {
+	Removed Hunk
+	Added   Hunk
+	// The changes to the old file, or to each parent of a combined diff
+	Parents []*Parent
+	// Number of lines removed and added, less than the counts of the
+	// ranges when the diff has context lines
+	NumRemoved int
+	NumAdded   int
+	// The hunk header followed by the lines of the hunk
+	Lines Lines
+}
+
+// AddedLines returns the content of the lines added by the hunk, those in
+// the result but not in some parent, when its lines are kept.
+func (h *HunkPair) AddedLines() Lines {
+	return h.changedLines('+')
+}
+
+// RemovedLines returns the content of the lines removed by the hunk, those
+// in some parent but not in the result, when its lines are kept.
+func (h *HunkPair) RemovedLines() Lines {
+	return h.changedLines('-')
+}
+
+func (h *HunkPair) changedLines(kind byte) Lines {
+	var lines Lines
+	n := len(h.Parents)
+	for i, line := range h.Lines {
+		if i == 0 || len(line) < n || len(line) > 0 && line[0] == '\\' {
+			// the header and "\ No newline at end of file"
+			continue
+		}
+		columns := line[:n]
+		removed := bytes.IndexByte(columns, '-') >= 0
+		added := !removed && bytes.IndexByte(columns, '+') >= 0
+		if kind == '-' && removed || kind == '+' && added {
+			lines = append(lines, line[n:])
+		}
+	}
+	return lines
+}
+
+type Hunks []*HunkPair
+
+func (h Hunks) String() string {
+	var lines []string
+	for _, h := range h {
+		lines = append(lines, h.Lines.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type Diff struct {
+	// Total number of lines added
+	Added int
+	// Total number of lines removed
+	Removed int
+	// Modes of the file before and after, from the extended headers, ""
+	// when the file is added or deleted or no header gives them
+	OldMode string
+	NewMode string
+	Hunks   Hunks
+}
+
+func (d *Diff) String() string {
+	return fmt.Sprintf("Added: %d\n"+
+		"Removed: %d\n"+
+		"Modes: %s %s\n"+
+		"Hunks: %s",
+		d.Added, d.Removed, d.OldMode, d.NewMode, d.Hunks)
+}
+
+// ModeChanged tells whether the mode of the file changed, for instance
+// when it is made executable.
+func (d *Diff) ModeChanged() bool {
+	return d.OldMode != "" && d.NewMode != "" && d.OldMode != d.NewMode
+}
+
+// parseHeader picks the modes of the file from an extended header line.
+func (d *Diff) parseHeader(line []byte) {
+	switch {
+	case bytes.HasPrefix(line, []byte("old mode ")):
+		d.OldMode = string(line[len("old mode "):])
+	case bytes.HasPrefix(line, []byte("new mode ")):
+		d.NewMode = string(line[len("new mode "):])
+	case bytes.HasPrefix(line, []byte("deleted file mode ")):
+		d.OldMode = string(line[len("deleted file mode "):])
+		d.NewMode = ""
+	case bytes.HasPrefix(line, []byte("new file mode ")):
+		d.OldMode = ""
+		d.NewMode = string(line[len("new file mode "):])
+	case bytes.HasPrefix(line, []byte("diff ")):
+		// the headers of the next file
+		d.OldMode, d.NewMode = "", ""
+	case bytes.HasPrefix(line, []byte("index ")):
+		// index <old>..<new> <mode>, when the mode is unchanged
+		if fields := bytes.Fields(line); len(fields) == 3 {
+			d.OldMode = string(fields[2])
+			d.NewMode = d.OldMode
+		}
+	}
+}
+
+// NewDiff parses the unified diff read from r.
+func NewDiff(r io.Reader) (Diff, error) {
+	return Parse(r, true)
+}
+
+// Parse parses the unified diff read from r as a stream. Only the hunk
+// headers are kept unless keepLines is true, so that memory stays bounded
+// on very large diffs.
+//
+// The line counts of each hunk header are used to tell where the hunk
+// ends, so the extended headers (diff --git, index, mode changes, ---/+++)
+// of the files that follow are skipped rather than taken as hunk lines.
+// Context lines are allowed, the changed lines are told apart in Parents.
+// The modes of the file are taken from the extended headers, those of the
+// last file for diffs of several files.
+//
+// Combined diffs (diff --cc, with @@@ hunk headers) are parsed as well.
+func Parse(r io.Reader, keepLines bool) (Diff, error) {
+	d := Diff{}
+	br := bufio.NewReader(r)
+
+	var currHunkPair *HunkPair
+	var curr *hunkReader
+	for lnum := 1; ; lnum++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return d, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		// CRLF line endings, the content is kept as is whatever its
+		// encoding
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+
+		if isHunkHeader(line) {
+			parents, added, perr := parseHunkHeader(line)
+			if perr != nil {
+				return d, fmt.Errorf("line %d: %v", lnum, perr)
+			}
+			curr = newHunkReader(parents, added)
+			currHunkPair = &HunkPair{
+				Removed: parents[0],
+				Added:   added,
+				Parents: curr.parents,
+				Lines:   Lines{line},
+			}
+			d.Hunks = append(d.Hunks, currHunkPair)
+			continue
+		}
+		if currHunkPair == nil {
+			// extended headers before the first hunk
+			d.parseHeader(line)
+			continue
+		}
+		if curr.done() {
+			// "\ No newline at end of file" belongs to the hunk just
+			// completed, anything else is the header of the next file.
+			if len(line) > 0 && line[0] == '\\' {
+				if keepLines {
+					currHunkPair.Lines = append(currHunkPair.Lines, line)
+				}
+			} else {
+				d.parseHeader(line)
+			}
+			continue
+		}
+
+		kind, herr := curr.add(line)
+		if herr != nil {
+			return d, fmt.Errorf("line %d: hunk %q: %v", lnum, currHunkPair.Lines[0], herr)
+		}
+		switch kind {
+		case '-':
+			currHunkPair.NumRemoved++
+			d.Removed++
+		case '+':
+			currHunkPair.NumAdded++
+			d.Added++
+		}
+		if keepLines {
+			currHunkPair.Lines = append(currHunkPair.Lines, line)
+		}
+	}
+	if curr != nil && !curr.done() {
+		return d, fmt.Errorf("unexpected end of diff in hunk %q", currHunkPair.Lines[0])
+	}
+
+	return d, nil
+}
+
+// isHunkHeader returns true for the lines starting with two or more @
+// followed by " -".
+func isHunkHeader(line []byte) bool {
+	n := 0
+	for n < len(line) && line[n] == '@' {
+		n++
+	}
+	return n >= 2 && bytes.HasPrefix(line[n:], []byte(" -"))
+}
+
+// parseHunkHeader parses a hunk header of the form
+//
+//	@@ -start[,count] +start[,count] @@ [section heading]
+//
+// or, for a combined diff of N parents, N+1 @ and N ranges prefixed
+// with -.
+//
+// Reference: https://www.gnu.org/software/diffutils/manual/html_node/Detailed-Unified.html#Detailed-Unified
+func parseHunkHeader(line []byte) (parents []Hunk, added Hunk, err error) {
+	marker := line[:bytes.IndexByte(line, ' ')]
+	nparents := len(marker) - 1
+	chunks := bytes.SplitN(line, space, nparents+4)
+	if len(chunks) < nparents+3 || !bytes.Equal(chunks[nparents+2], marker) {
+		return nil, added, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	for _, numbers := range chunks[1 : nparents+1] {
+		removed, err := toHunk(numbers, '-')
+		if err != nil {
+			return nil, added, fmt.Errorf("invalid hunk header %q: %v", line, err)
+		}
+		parents = append(parents, removed)
+	}
+	if added, err = toHunk(chunks[nparents+1], '+'); err != nil {
+		return nil, added, fmt.Errorf("invalid hunk header %q: %v", line, err)
+	}
+	return parents, added, nil
+}
+
+// toHunk parses one side of a hunk header. The count defaults to 1 when
+// it is left out.
+func toHunk(numbers []byte, sign byte) (Hunk, error) {
+	if len(numbers) == 0 || numbers[0] != sign {
+		return Hunk{}, fmt.Errorf("range %q does not start with %c", numbers, sign)
+	}
+	lineRange := numbers[1:]
+	count := 1
+	if i := bytes.Index(lineRange, comma); i >= 0 {
+		n, err := toNumber(lineRange[i+1:])
+		if err != nil {
+			return Hunk{}, err
+		}
+		count = n
+		lineRange = lineRange[:i]
+	}
+	start, err := toNumber(lineRange)
+	if err != nil {
+		return Hunk{}, err
+	}
+	return Hunk{
+		Start: start,
+		Count: count,
+	}, nil
+}
+
+func toNumber(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, fmt.Errorf("missing line number")
+	}
+	for _, c := range buf {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid line number %q", buf)
+		}
+	}
+	n, err := strconv.Atoi(string(buf))
+	if err != nil {
+		return 0, fmt.Errorf("invalid line number %q", buf)
+	}
+	return n, nil
+}
+
+// hunkReader follows the lines of a hunk, each of them prefixed with one
+// column per parent: one for plain diffs, more for combined diffs.
+type hunkReader struct {
+	parents []*Parent
+	// next line number in each parent
+	next []int
+	// lines of each parent left to read
+	left []int
+	// whether the previous line changed each parent
+	changing  []bool
+	addedLeft int
+}
+
+func newHunkReader(parents []Hunk, added Hunk) *hunkReader {
+	h := &hunkReader{addedLeft: added.Count}
+	for _, p := range parents {
+		next := p.Start
+		if p.Count == 0 {
+			// the start is the line before an empty range
+			next++
+		}
+		h.parents = append(h.parents, &Parent{Hunk: p})
+		h.next = append(h.next, next)
+		h.left = append(h.left, p.Count)
+		h.changing = append(h.changing, false)
+	}
+	return h
+}
+
+func (h *hunkReader) done() bool {
+	if h.addedLeft > 0 {
+		return false
+	}
+	for _, left := range h.left {
+		if left > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add accounts for a line of the hunk and returns whether it was removed
+// (-), added (+) or is context (space). A - in the column of a parent
+// means the line is in that parent but not in the result, a + that the
+// line is in the result but not in that parent.
+func (h *hunkReader) add(line []byte) (byte, error) {
+	n := len(h.parents)
+	if len(line) > 0 && line[0] == '\\' {
+		return '\\', nil
+	}
+	columns := bytes.Repeat(space, n) // some tools strip the space of empty context lines
+	if len(line) > 0 {
+		if len(line) < n {
+			return 0, fmt.Errorf("unexpected line %q", line)
+		}
+		columns = line[:n]
+	}
+	removed := bytes.IndexByte(columns, '-') >= 0
+	for _, c := range columns {
+		if c != ' ' && c != '-' && c != '+' || removed && c == '+' {
+			return 0, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+	kind := byte(' ')
+	switch {
+	case removed:
+		kind = '-'
+	case bytes.IndexByte(columns, '+') >= 0:
+		kind = '+'
+	}
+	if !removed {
+		h.addedLeft--
+	}
+	for i, c := range columns {
+		p := h.parents[i]
+		switch {
+		case c == '-':
+			p.Removed = append(p.Removed, h.next[i])
+			h.changing[i] = true
+		case c == '+':
+			if !h.changing[i] {
+				p.Inserted = append(p.Inserted, h.next[i]-1)
+			}
+			h.changing[i] = true
+			continue
+		case removed:
+			// removed from another parent, not in this one
+			continue
+		default:
+			h.changing[i] = false
+		}
+		h.next[i]++
+		h.left[i]--
+	}
+	for _, left := range h.left {
+		if left < 0 {
+			return 0, fmt.Errorf("has more lines than its header says")
+		}
+	}
+	if h.addedLeft < 0 {
+		return 0, fmt.Errorf("has more lines than its header says")
+	}
+	return kind, nil
+}
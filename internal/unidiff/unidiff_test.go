@@ -0,0 +1,409 @@
+package unidiff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+
+	tests := []struct {
+		diff string
+		want Diff
+	}{
+		{
+			diff: `diff --git main.go main.go
+index e953e19..60856c9 100755
+--- main.go
++++ main.go
+@@ -1 +0,0 @@
+-// hello
+@@ -16,0 +16,1 @@ import (
++// Line Added
+`,
+			want: Diff{
+				Added:   1,
+				Removed: 1,
+				OldMode: "100755",
+				NewMode: "100755",
+				Hunks: Hunks{
+					hunkPair(1, 1, 0, 0, "@@ -1 +0,0 @@\n-// hello"),
+					hunkPair(16, 0, 16, 1, "@@ -16,0 +16,1 @@ import (\n+// Line Added"),
+				},
+			},
+		},
+		{
+			diff: `index e953e19..80cee70 100755
+--- main.go
++++ main.go
+@@ -1 +0,0 @@
+-// hello
+@@ -16,0 +16 @@ import (
++// Line added at middle of file
+@@ -296,0 +298 @@ func bail(format string, args ...interface{}) {
++// Line added at end of file
+`,
+			want: Diff{
+				Added:   2,
+				Removed: 1,
+				OldMode: "100755",
+				NewMode: "100755",
+				Hunks: Hunks{
+					hunkPair(1, 1, 0, 0, "@@ -1 +0,0 @@\n-// hello"),
+					hunkPair(16, 0, 16, 1, "@@ -16,0 +16 @@ import (\n+// Line added at middle of file"),
+					hunkPair(296, 0, 298, 1, "@@ -296,0 +298 @@ func bail(format string, args ...interface{}) {\n+// Line added at end of file"),
+				},
+			},
+		},
+		{
+			diff: `diff --git main.go main.go
+index 4e3ecb5..b7d5fd7 100755
+--- main.go
++++ main.go
+@@ -1 +0,0 @@
+-// hello
+`,
+			want: Diff{
+				Added:   0,
+				Removed: 1,
+				OldMode: "100755",
+				NewMode: "100755",
+				Hunks: Hunks{
+					hunkPair(1, 1, 0, 0, "@@ -1 +0,0 @@\n-// hello"),
+				},
+			},
+		},
+		{
+			// several files, with context lines and extended headers
+			diff: `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+diff --git a/b.txt b/b.txt
+old mode 100644
+new mode 100755
+index 3333333..4444444
+--- a/b.txt
++++ b/b.txt
+@@ -5 +5,2 @@
+-five
++five
++six
+\ No newline at end of file
+`,
+			want: Diff{
+				Added:   3,
+				Removed: 2,
+				OldMode: "100644",
+				NewMode: "100755",
+				Hunks: Hunks{
+					hunkPair(1, 3, 1, 3, "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three").changed([]int{2}, nil, 1, 1),
+					hunkPair(5, 1, 5, 2, "@@ -5 +5,2 @@\n-five\n+five\n+six\n\\ No newline at end of file"),
+				},
+			},
+		},
+		{
+			// removed lines that look like file headers
+			diff: `--- a/x
++++ b/x
+@@ -1,2 +0,0 @@
+--- a/x
+-+++ b/x
+@@ -10,0 +9 @@ func f() {
+++++ b/x
+`,
+			want: Diff{
+				Added:   1,
+				Removed: 2,
+				Hunks: Hunks{
+					hunkPair(1, 2, 0, 0, "@@ -1,2 +0,0 @@\n--- a/x\n-+++ b/x"),
+					hunkPair(10, 0, 9, 1, "@@ -10,0 +9 @@ func f() {\n++++ b/x"),
+				},
+			},
+		},
+		{
+			// empty context line with its space stripped
+			diff: "@@ -1,3 +1,3 @@\n-a\n\n+b\n c\n",
+			want: Diff{
+				Added:   1,
+				Removed: 1,
+				Hunks: Hunks{
+					hunkPair(1, 3, 1, 3, "@@ -1,3 +1,3 @@\n-a\n\n+b\n c").changed([]int{1}, []int{2}, 1, 1),
+				},
+			},
+		},
+		{
+			// binary files and pure renames have no hunks
+			diff: `diff --git a/img.png b/img.png
+index 5555555..6666666 100644
+Binary files a/img.png and b/img.png differ
+diff --git a/old b/new
+similarity index 100%
+rename from old
+rename to new
+`,
+			want: Diff{},
+		},
+		{
+			// CRLF line endings and Latin-1 content
+			diff: "--- a/x\r\n+++ b/x\r\n@@ -1,2 +1,2 @@\r\n-caf\xe9\r\n+caf\xe9 cr\rlf\r\n \xff\r\n",
+			want: Diff{
+				Added:   1,
+				Removed: 1,
+				Hunks: Hunks{
+					hunkPair(1, 2, 1, 2, "@@ -1,2 +1,2 @@\n-caf\xe9\n+caf\xe9 cr\rlf\n \xff").changed([]int{1}, nil, 1, 1),
+				},
+			},
+		},
+		{
+			// mode change only
+			diff: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+			want: Diff{OldMode: "100644", NewMode: "100755"},
+		},
+		{
+			diff: "",
+			want: Diff{},
+		},
+	}
+
+	for i, tt := range tests {
+		b := bytes.NewReader([]byte(tt.diff))
+		got, err := NewDiff(b)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tests[%d] failed\nwant: %s\n got: %s", i, &tt.want, &got)
+		}
+	}
+}
+
+func TestParseHeadersOnly(t *testing.T) {
+	diff := "--- main.go\r\n+++ main.go\r\n@@ -1 +0,0 @@\r\n-// hello\r\n@@ -16,0 +16 @@ import (\r\n+// Line added"
+	got, err := Parse(bytes.NewReader([]byte(diff)), false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := Diff{
+		Added:   1,
+		Removed: 1,
+		Hunks: Hunks{
+			hunkPair(1, 1, 0, 0, "@@ -1 +0,0 @@"),
+			hunkPair(16, 0, 16, 1, "@@ -16,0 +16 @@ import ("),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %s\n got: %s", &want, &got)
+	}
+}
+
+func TestParseCombined(t *testing.T) {
+	diff := `diff --cc f
+index 06107bb,1e995f9..0000000
+--- a/f
++++ b/f
+@@@ -1,3 -1,4 +1,10 @@@
+++<<<<<<< HEAD
+ +l1a
+- l2
+++l2z
+ +X
+++=======
++ l0
++ l1
+ -l2
+++l2z
++ Y
+++>>>>>>> other
+@@@ -9,0 -10,1 +16,0 @@@
+ -gone
+diff --cc g
+--- a/g
++++ b/g
+@@@ -3 -2,0 +3 @@@
+- old
+++new
+`
+	got, err := NewDiff(bytes.NewReader([]byte(diff)))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := [][]*Parent{
+		{
+			{Hunk: Hunk{Start: 1, Count: 3}, Removed: []int{2}, Inserted: []int{0, 3}},
+			{Hunk: Hunk{Start: 1, Count: 4}, Removed: []int{3}, Inserted: []int{0, 4}},
+		},
+		{
+			{Hunk: Hunk{Start: 9, Count: 0}},
+			{Hunk: Hunk{Start: 10, Count: 1}, Removed: []int{10}},
+		},
+		{
+			{Hunk: Hunk{Start: 3, Count: 1}, Removed: []int{3}},
+			{Hunk: Hunk{Start: 2, Count: 0}, Inserted: []int{2}},
+		},
+	}
+	if len(got.Hunks) != len(want) {
+		t.Fatalf("got %d hunks, want %d:\n%s", len(got.Hunks), len(want), &got)
+	}
+	for i, hunk := range got.Hunks {
+		if !reflect.DeepEqual(hunk.Parents, want[i]) {
+			t.Errorf("hunks[%d]: got parents %+v, want %+v", i, hunk.Parents, want[i])
+		}
+		if hunk.Removed != want[i][0].Hunk {
+			t.Errorf("hunks[%d]: removed %+v, want the first parent %+v", i, hunk.Removed, want[i][0].Hunk)
+		}
+	}
+	if got.Added != 11 || got.Removed != 4 {
+		t.Errorf("got %d added, %d removed, want 11, 4", got.Added, got.Removed)
+	}
+	if n := len(got.Hunks[0].Lines); n != 13 {
+		t.Errorf("got %d lines in the first hunk, want 13", n)
+	}
+	if n := len(got.Hunks[0].AddedLines()); n != 10 {
+		t.Errorf("got %d added lines in the first hunk, want 10", n)
+	}
+	if added := got.Hunks[2].AddedLines().String(); added != "new" {
+		t.Errorf("got added lines %q in the last hunk, want %q", added, "new")
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	diff := "@@ -1,3 +1,4 @@\n a\n-b\n+B\n+\n c\n\\ No newline at end of file\n"
+	got, err := NewDiff(bytes.NewReader([]byte(diff)))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := Lines{[]byte("B"), []byte("")}
+	if added := got.Hunks[0].AddedLines(); !reflect.DeepEqual(added, want) {
+		t.Errorf("got added lines %q, want %q", added, want)
+	}
+	want = Lines{[]byte("b")}
+	if removed := got.Hunks[0].RemovedLines(); !reflect.DeepEqual(removed, want) {
+		t.Errorf("got removed lines %q, want %q", removed, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		diff string
+		want string
+	}{
+		{"@@ -1 +1\n", "line 1: invalid hunk header"},
+		{"@@ -1 +1 @\n", "line 1: invalid hunk header"},
+		{"@@ - +1 @@\n", "missing line number"},
+		{"@@ -1, +1 @@\n", "missing line number"},
+		{"@@ -a,1 +1 @@\n", `invalid line number "a"`},
+		{"@@ -1,-2 +1 @@\n", `invalid line number "-2"`},
+		{"@@ -1 1 @@\n", "does not start with +"},
+		{"@@ -1 -1 @@\n", "does not start with +"},
+		{"@@ -99999999999999999999 +1 @@\n", "invalid line number"},
+		{"--- a\n+++ b\n@@ -1 +1 @@\n-a\n*b\n", `line 5: hunk "@@ -1 +1 @@": unexpected line "*b"`},
+		{"@@ -1 +1 @@\n-a\n-b\n", `line 3: hunk "@@ -1 +1 @@": has more lines`},
+		{"@@ -1,2 +1 @@\n-a\n+b\n", "unexpected end of diff"},
+		{"@@@ -1 -1 +1 @@\n", "line 1: invalid hunk header"},
+		{"@@@ -1 -1 +1 @@@\n+-a\n", "line 2: hunk \"@@@ -1 -1 +1 @@@\": unexpected line"},
+		{"@@@ -1 -1 +1 @@@\n-\n", "line 2: hunk \"@@@ -1 -1 +1 @@@\": unexpected line"},
+		{"@@@ -1 -1 +1 @@@\n -a\n--b\n", "more lines than its header says"},
+		{"@@@ -1 -1 +1 @@@\n++a\n", "unexpected end of diff"},
+	}
+	for i, tt := range tests {
+		_, err := NewDiff(bytes.NewReader([]byte(tt.diff)))
+		if err == nil {
+			t.Errorf("tests[%d]: no error for %q", i, tt.diff)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("tests[%d]: error %q does not contain %q", i, err, tt.want)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"@@ -1 +0,0 @@\n-// hello\n@@ -16,0 +16 @@ import (\n+// Line added\n",
+		"diff --git a/a b/a\nindex 1..2 100644\n--- a/a\n+++ b/a\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+		"@@ -5 +5 @@\n-x\n+y\n",
+		"@@ -1,3 +1,3 @@\r\n-a\r\n\r\n+b\r\n c\r\n",
+		"@@ -1 +1\n",
+		"@@ -, +, @@\n",
+		"@@@ -1,1 -1,1 +1,1 @@@\n- a\n -b\n++c\n",
+		"@@@@ -1 -1 -0,0 +1 @@@@\n  +a\n--- b\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, diff []byte) {
+		got, err := Parse(bytes.NewReader(diff), true)
+		if err != nil {
+			return
+		}
+		added, removed := 0, 0
+		for _, h := range got.Hunks {
+			if len(h.Lines) == 0 || !isHunkHeader(h.Lines[0]) {
+				t.Fatalf("hunk does not start with its header: %q", h.Lines)
+			}
+			if h.NumAdded > h.Added.Count {
+				t.Fatalf("%d lines added in %q", h.NumAdded, h.Lines[0])
+			}
+			for _, p := range h.Parents {
+				if len(p.Removed) > p.Count {
+					t.Fatalf("%d lines removed in %q", len(p.Removed), h.Lines[0])
+				}
+			}
+			added += h.NumAdded
+			removed += h.NumRemoved
+		}
+		if added != got.Added || removed != got.Removed {
+			t.Fatalf("totals %d/%d do not match the hunks %d/%d", got.Added, got.Removed, added, removed)
+		}
+
+		headers, err := Parse(bytes.NewReader(diff), false)
+		if err != nil {
+			t.Fatalf("headers only: %v", err)
+		}
+		if len(headers.Hunks) != len(got.Hunks) {
+			t.Fatalf("headers only: %d hunks, want %d", len(headers.Hunks), len(got.Hunks))
+		}
+	})
+}
+
+// hunkPair returns the hunk of a diff without context lines.
+func hunkPair(rstart, rcount, astart, acount int, lines string) *HunkPair {
+	var diff Lines
+	for _, line := range strings.Split(lines, "\n") {
+		diff = append(diff, []byte(line))
+	}
+	parent := &Parent{Hunk: Hunk{Start: rstart, Count: rcount}}
+	for lnum := rstart; lnum < rstart+rcount; lnum++ {
+		parent.Removed = append(parent.Removed, lnum)
+	}
+	if rcount == 0 && acount > 0 {
+		parent.Inserted = []int{rstart}
+	}
+	return &HunkPair{
+		Removed:    Hunk{Start: rstart, Count: rcount},
+		Added:      Hunk{Start: astart, Count: acount},
+		Parents:    []*Parent{parent},
+		NumRemoved: rcount,
+		NumAdded:   acount,
+		Lines:      diff,
+	}
+}
+
+// changed sets the changed lines of a hunk with context lines.
+func (h *HunkPair) changed(removed, inserted []int, numRemoved, numAdded int) *HunkPair {
+	h.Parents[0].Removed = removed
+	h.Parents[0].Inserted = inserted
+	h.NumRemoved = numRemoved
+	h.NumAdded = numAdded
+	return h
+}
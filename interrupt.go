@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type cmdContextValue struct{ context.Context }
+
+var (
+	// context of the commands run, a cmdContextValue canceled on
+	// interrupt to kill them
+	cmdContext atomic.Value
+	cancelCmds context.CancelFunc
+
+	interruptOnce sync.Once
+	interrupted   = make(chan struct{})
+	isInterrupted int32
+
+	// set while the results are being collected, to show the partial
+	// results on interrupt
+	interruptHandled int32
+)
+
+func init() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmdContext.Store(cmdContextValue{ctx})
+	cancelCmds = cancel
+}
+
+// command returns the command to run with the arguments, killed on
+// interrupt and, for git, after -git-timeout. done must be called with the
+// error of the command once it is over, it returns the error to report.
+// The git commands are traced with -trace, timed from the call to command.
+func command(name string, arg ...string) (cmd *exec.Cmd, done func(error) error) {
+	ctx := cmdContext.Load().(cmdContextValue).Context
+	var cancel context.CancelFunc
+	if name == "git" && optGitTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, optGitTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	cmd = exec.CommandContext(ctx, name, arg...)
+	start := time.Now()
+	return cmd, func(err error) error {
+		defer cancel()
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("%s %s: timed out after %v", name, subcommand(arg), optGitTimeout)
+		}
+		if optTrace && name == "git" {
+			traceCommand(arg, time.Since(start), err)
+		}
+		return err
+	}
+}
+
+// subcommand returns the git command of the arguments, the first one that
+// is not an option.
+func subcommand(arg []string) string {
+	for i := 0; i < len(arg); i++ {
+		switch {
+		case arg[i] == "-c" || arg[i] == "-C":
+			i++
+		case !strings.HasPrefix(arg[i], "-"):
+			return arg[i]
+		}
+	}
+	return ""
+}
+
+// handleInterrupts kills the running commands on SIGINT or SIGTERM. The
+// results collected so far are shown when the interrupt comes while
+// collecting them, see interruptHandled, and the functions registered with
+// onExit run, deleting temporary refs and files.
+func handleInterrupts() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		interruptOnce.Do(func() {
+			atomic.StoreInt32(&isInterrupted, 1)
+			// the commands run from now on, to clean up or show the
+			// results, are not killed
+			cmdContext.Store(cmdContextValue{context.Background()})
+			cancelCmds()
+			close(interrupted)
+		})
+		if atomic.LoadInt32(&interruptHandled) == 1 {
+			// leave some time for the results to be shown
+			time.Sleep(5 * time.Second)
+		}
+		warn("interrupted")
+		exit(130)
+	}()
+}
+
+// stopIfInterrupted ends the calling goroutine, instead of failing, when
+// its command was killed by an interrupt.
+func stopIfInterrupted() {
+	if atomic.LoadInt32(&isInterrupted) == 1 {
+		runtime.Goexit()
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"regexp"
+)
+
+// issueRegexp matches JIRA style issue keys (PROJ-123) and GitHub style
+// issue references (#123).
+var issueRegexp = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b|(?:^|[^&\w])(#[0-9]+)\b`)
+
+// extractIssues returns the issue references found in the commit message,
+// in order of appearance and without duplicates.
+func extractIssues(message []byte) []string {
+	var issues []string
+	seen := map[string]bool{}
+	for _, m := range issueRegexp.FindAllSubmatch(message, -1) {
+		issue := string(m[0])
+		if m[1] != nil {
+			issue = string(m[1])
+		}
+		if !seen[issue] {
+			seen[issue] = true
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func getCommitIssues(sha1 string) []string {
+	return issuesCache.get(sha1, func() []string {
+		return extractIssues(run("git", "show", "--no-patch", "--format=%B", sha1))
+	})
+}
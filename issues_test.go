@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractIssues(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []string
+	}{
+		{"Fix crash (PROJ-12)\n\nSee also #34 and ABC2-5, PROJ-12 again.", []string{"PROJ-12", "#34", "ABC2-5"}},
+		{"#7: fix typo", []string{"#7"}},
+		{"Neither &#39; nor a1#2 are issues", nil},
+		{"No issue here", nil},
+	}
+
+	for i, tt := range tests {
+		got := extractIssues([]byte(tt.message))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tests[%d] failed\nwant: %q\n got: %q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// jsonReport is the output of -json, which -compare reads back.
+type jsonReport struct {
+	Files      []jsonFile `json:"files"`
+	CommonTags []string   `json:"common_tags"`
+}
+
+type jsonFile struct {
+	File       string       `json:"file"`
+	Removed    int          `json:"removed"`
+	Added      int          `json:"added"`
+	Unchanged  bool         `json:"unchanged,omitempty"`
+	Commits    []jsonCommit `json:"commits"`
+	CommonTags []string     `json:"common_tags"`
+}
+
+type jsonCommit struct {
+	Commit   string   `json:"commit"`
+	Tags     []string `json:"tags"`
+	Branches []string `json:"branches"`
+	Lines    []int    `json:"lines"`
+	Issues   []string `json:"issues,omitempty"`
+}
+
+func toJSON(reports []*FileReport, commonTags MergeBaseTags) jsonReport {
+	j := jsonReport{Files: []jsonFile{}, CommonTags: nonNil(commonTags)}
+	for _, r := range reports {
+		f := jsonFile{
+			File:       r.File,
+			Removed:    r.Diff.Removed,
+			Added:      r.Diff.Added,
+			Unchanged:  r.Unchanged,
+			Commits:    []jsonCommit{},
+			CommonTags: nonNil(r.CommonTags),
+		}
+		for _, sha1 := range r.sortedCommits() {
+			f.Commits = append(f.Commits, jsonCommit{
+				Commit:   sha1,
+				Tags:     nonNil(r.Commits[sha1]),
+				Branches: nonNil(r.Branches[sha1]),
+				Lines:    r.Lines[sha1],
+				Issues:   r.Issues[sha1],
+			})
+		}
+		j.Files = append(j.Files, f)
+	}
+	return j
+}
+
+// nonNil returns the strings, an empty slice for none so that they are
+// encoded as [] rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// showJSON prints the -json report of the files.
+func showJSON(reports []*FileReport, commonTags MergeBaseTags) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(toJSON(reports, commonTags)); err != nil {
+		bail("error: %v", err)
+	}
+}
+
+// readJSON reads a report written with -json.
+func readJSON(name string) (jsonReport, error) {
+	var j jsonReport
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return j, err
+	}
+	err = json.Unmarshal(b, &j)
+	return j, err
+}
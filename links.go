@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// forgeURL returns the web URL of the repository of the remote on its
+// forge, e.g. https://github.com/org/repo, "" when the remote is not a
+// network one.
+func forgeURL(remote string) string {
+	host, path := remoteHostPath(remote)
+	if host == "" {
+		return ""
+	}
+	return "https://" + host + "/" + path
+}
+
+// isGitLab tells whether the forge at the URL is a GitLab, whose pages are
+// under /-/.
+func isGitLab(url string) bool {
+	return strings.Contains(url, "gitlab")
+}
+
+// remoteCommitURL returns the pattern of the URLs of the commits of the
+// remote, "" when it cannot tell.
+func remoteCommitURL(remote string) string {
+	base := forgeURL(remote)
+	switch {
+	case base == "":
+		return ""
+	case isGitLab(base):
+		return base + "/-/commit/%H"
+	case strings.Contains(base, "bitbucket"):
+		return base + "/commits/%H"
+	}
+	return base + "/commit/%H"
+}
+
+// commitURL returns the URL of the commit with -commit-url.
+func commitURL(sha1 string) string {
+	return strings.ReplaceAll(optCommitURL, "%H", sha1)
+}
+
+// forgeBase is the web URL of the repository that -links point to.
+var forgeBase string
+
+// linksBase returns the web URL of the repository, the one of -commit-url
+// when it is given, or the one of the first -remote.
+func linksBase() string {
+	for _, suffix := range []string{"/-/commit/%H", "/commits/%H", "/commit/%H"} {
+		if strings.HasSuffix(optCommitURL, suffix) {
+			return strings.TrimSuffix(optCommitURL, suffix)
+		}
+	}
+	return forgeURL(remotes[0])
+}
+
+// lineURL returns the permalink of the line of the file in the commit.
+func lineURL(sha1, path string, lnum int) string {
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	switch {
+	case isGitLab(forgeBase):
+		return fmt.Sprintf("%s/-/blob/%s/%s#L%d", forgeBase, sha1, escaped, lnum)
+	case strings.Contains(forgeBase, "bitbucket"):
+		return fmt.Sprintf("%s/src/%s/%s#lines-%d", forgeBase, sha1, escaped, lnum)
+	}
+	return fmt.Sprintf("%s/blob/%s/%s#L%d", forgeBase, sha1, escaped, lnum)
+}
+
+// showLinks prints the permalinks of the lines of the affected commit, in
+// the file as it was named in the commit.
+func showLinks(r *FileReport, sha1 string) {
+	path := r.SourcePaths[sha1]
+	if path == "" {
+		path = repoPath(r.File)
+	}
+	for _, lnum := range r.Sources[sha1] {
+		fmt.Printf("\t\t%s\n", lineURL(sha1, path, lnum))
+	}
+}
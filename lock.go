@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"time"
+)
+
+// lockRetry paces the retries of a git command that failed because another
+// git process, such as the git integration of an editor, holds a lock file
+// like index.lock. The command is retried for up to -lock-timeout.
+type lockRetry struct {
+	deadline time.Time
+	delay    time.Duration
+}
+
+func newLockRetry() *lockRetry {
+	return &lockRetry{deadline: time.Now().Add(optLockTimeout), delay: 50 * time.Millisecond}
+}
+
+// again tells whether to run again the command that failed with the error
+// output, after waiting for the lock to be released.
+func (r *lockRetry) again(stderr []byte) bool {
+	if !isLockError(stderr) || time.Now().Add(r.delay).After(r.deadline) {
+		return false
+	}
+	time.Sleep(r.delay)
+	if r.delay *= 2; r.delay > time.Second {
+		r.delay = time.Second
+	}
+	return true
+}
+
+// isLockError tells whether git failed to create a lock file because it
+// exists, as in "fatal: Unable to create '.git/index.lock': File exists."
+func isLockError(stderr []byte) bool {
+	return bytes.Contains(stderr, []byte(".lock': File exists"))
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+}
+
+// setupLogging sends the diagnostics of the -log-level level and above to
+// the standard error, as key=value pairs.
+func setupLogging(level string) {
+	l, ok := logLevels[level]
+	if !ok {
+		bail("error: -log-level must be debug, info or warn")
+	}
+	h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l})
+	slog.SetDefault(slog.New(h))
+}
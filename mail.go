@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportText renders the reports as plain text.
+func reportText(reports []*FileReport, commonTags MergeBaseTags) string {
+	b := &bytes.Buffer{}
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Fprintln(b)
+		}
+		fmt.Fprintf(b, "%s\n", r.File)
+		fmt.Fprintf(b, "    Lines: %d removed, %d added\n", r.Diff.Removed, r.Diff.Added)
+		fmt.Fprintf(b, "    Commits affected:\n")
+		for _, sha1 := range r.sortedCommits() {
+			fmt.Fprintf(b, "\t%s (%s)", sha1, limitedBranches(r.Branches[sha1]))
+			if issues := r.Issues[sha1]; len(issues) > 0 {
+				fmt.Fprintf(b, " [%s]", strings.Join(issues, ", "))
+			}
+			fmt.Fprintln(b)
+			fmt.Fprintf(b, "\tlines: %s\n", joinInts(r.Lines[sha1]))
+		}
+		if len(r.CommonTags) > 0 {
+			fmt.Fprintf(b, "    Common tag:\n\t%s\n", r.CommonTags)
+		} else {
+			fmt.Fprintf(b, "    No common tags found for all the affected commits.\n")
+		}
+	}
+	if len(reports) > 1 {
+		fmt.Fprintln(b)
+		if len(commonTags) > 0 {
+			fmt.Fprintf(b, "COMMON TAG: %s\n", commonTags)
+		} else {
+			fmt.Fprintf(b, "NO COMMON TAG\n")
+		}
+	}
+	return b.String()
+}
+
+// reportHTML renders the reports as an HTML document.
+func reportHTML(reports []*FileReport, commonTags MergeBaseTags) string {
+	b := &bytes.Buffer{}
+	e := html.EscapeString
+	fmt.Fprintf(b, "<html><body>\n")
+	for _, r := range reports {
+		fmt.Fprintf(b, "<h3>%s</h3>\n", e(r.File))
+		fmt.Fprintf(b, "<p>Lines: %d removed, %d added</p>\n", r.Diff.Removed, r.Diff.Added)
+		fmt.Fprintf(b, "<table border=\"1\" cellpadding=\"3\">\n")
+		fmt.Fprintf(b, "<tr><th>Commit</th><th>Lines</th><th>Branches</th><th>Issues</th></tr>\n")
+		for _, sha1 := range r.sortedCommits() {
+			fmt.Fprintf(b, "<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				e(shortSha1(sha1)), e(joinInts(r.Lines[sha1])), e(limitedBranches(r.Branches[sha1])),
+				e(strings.Join(r.Issues[sha1], ", ")))
+		}
+		fmt.Fprintf(b, "</table>\n")
+		if len(r.CommonTags) > 0 {
+			fmt.Fprintf(b, "<p>Common tag: <b>%s</b></p>\n", e(r.CommonTags.String()))
+		} else {
+			fmt.Fprintf(b, "<p><b>No common tags found for all the affected commits.</b></p>\n")
+		}
+	}
+	if len(reports) > 1 {
+		if len(commonTags) > 0 {
+			fmt.Fprintf(b, "<p>COMMON TAG: <b>%s</b></p>\n", e(commonTags.String()))
+		} else {
+			fmt.Fprintf(b, "<p><b>NO COMMON TAG</b></p>\n")
+		}
+	}
+	fmt.Fprintf(b, "</body></html>\n")
+	return b.String()
+}
+
+// mailReport sends the reports as a multipart plain text and HTML email
+// through the SMTP server at addr. SMTP authentication is used when
+// GIT_CHECK_DIFF_SMTP_USER and GIT_CHECK_DIFF_SMTP_PASSWORD are set.
+func mailReport(addr, from string, to []string, reports []*FileReport, commonTags MergeBaseTags) error {
+	var files []string
+	for _, r := range reports {
+		files = append(files, r.File)
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain", reportText(reports, commonTags)},
+		{"text/html", reportHTML(reports, commonTags)},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qw := quotedprintable.NewWriter(w)
+		qw.Write([]byte(part.content))
+		qw.Close()
+	}
+	mw.Close()
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", from)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(to, ", "))
+	subject := "git-check-diff: " + strings.Join(files, " ")
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%q\r\n", mw.Boundary())
+	fmt.Fprintf(msg, "\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if user := os.Getenv("GIT_CHECK_DIFF_SMTP_USER"); user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", user, os.Getenv("GIT_CHECK_DIFF_SMTP_PASSWORD"), host)
+	}
+	return smtp.SendMail(addr, auth, from, to, msg.Bytes())
+}
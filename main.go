@@ -4,55 +4,437 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
 )
 
 var (
-	optLimit    int
-	optAll      bool
-	optShowLine bool
-	optBefore   bool
-	optOffset   = 0
-	optAfter    bool
-	optShowDate bool
-	optCached   bool
-	optHunks    string
-	optShowHunk bool
+	optLimit       int
+	optAll         bool
+	optShowLine    bool
+	optBefore      bool
+	optOffset      int
+	optNeighbor    string
+	optAdded       string
+	optAddedVs     string
+	optMerges      string
+	optReverts     bool
+	optRelated     bool
+	optDetectMoves bool
+	optHistory     int
+	optBlameLines  int
+	optCommitURL   string
+	optLinks       bool
+	optOutput      string
+	optOutputDir   string
+	optPipe        string
+	optJSON        bool
+	optCompare     string
+	optMaxAffected int
+	optCommonOnly  bool
+	optShowTags    string
+	optProfile     string
+	optAfter       bool
+	optShowDate    bool
+	optDateFormat  string
+	optCached      bool
+	optHunks       string
+	optShowHunk    bool
+	optContext     int
+	optBaseRef     string
+	optUpstream    bool
+	optPR          string
+	optMR          string
+	optChange      string
+	optGerritLabel string
+	optWorktree    string
+	optFilesFrom   string
+	optNulData     bool
+	optRange       string
+
+	optRecurseSubmodules bool
+	optUntracked         bool
+	optLockTimeout       time.Duration
+	optGitTimeout        time.Duration
+	optMatrix            bool
+	optDot               string
+	optSqlite            string
+	optNotify            string
+	optMailTo            string
+	optMailFrom          string
+	optSMTP              string
+	optNotes             string
+	optTrailers          bool
+	optOwners            bool
+	optAuthor            bool
+	optByAuthor          bool
+
+	optExcludeAuthors stringsFlag
+	optTarget         string
+	optFirstRelease   bool
+	optPropagation    bool
+	optDistance       bool
+	optReleaseTags    string
+	optDefaultBranch  string
+	optRemotes        string
+	optLocalBranches  string
+	optNoCommitGraph  bool
+	optUnordered      bool
+	optMaxProcs       int
+	optCPUProfile     string
+	optMemProfile     string
+	optExecTrace      string
+	optTrace          bool
+	optLogLevel       string
 )
 
 type WantedHunks map[int]bool
 
+// stringsFlag is a flag that can be given more than once.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var codeOwners CodeOwners
+
+var (
+	// Remotes whose branches are checked for containment
+	remotes []string
+	// Integration branch of each remote
+	defaultBranches []string
+)
+
 func main() {
+	setGitEnv()
+
 	flag.BoolVar(&optAll, "all", false, "Show all merge base tags.")
-	flag.IntVar(&optLimit, "limit", 7, "Show only the given `number` of merge base tags. 0 is equivalent to -all.")
+	flag.IntVar(&optLimit, "limit", 7, "Show only the given `number` of merge base tags, and of branches of each commit.\n\t0 is equivalent to -all.")
 	flag.BoolVar(&optShowLine, "line", false, "Show the line numbers for each affected commit (will be shown\n\tregardless when there are no common commit).")
 	flag.BoolVar(&optBefore, "B", false, "Use the commit immediately preceeding the changed line. Useful for one-liner\n\tchange when the surrounding commit is newer than the changed line's.")
 	flag.BoolVar(&optAfter, "A", false, "Use the commit immediately following the changed line. Useful for one-liner\n\tchange when the surrounding commit is newer than the changed line's.")
+	flag.IntVar(&optOffset, "offset", 0, "Use the commit of the line `n` lines away from the changed line, before it\n\twhen negative, e.g. -offset -2 for the header of the block holding the change.\n\t-B is -offset -1 and -A is -offset 1.")
+	flag.StringVar(&optNeighbor, "neighbor", "", "Use the commits of the lines preceding and following each changed line:\n\tthe `oldest` or newest of the two, or both.")
+	flag.StringVar(&optAdded, "added", "before", "Attribute the lines added without removing any to the commit of the line\n\t`before` them, after them, both or skip them.")
+	flag.StringVar(&optAddedVs, "added-vs", "", "Also look for the added lines in the file on `branch`, e.g. a release branch,\n\tand show the commits that last changed those found there.")
+	flag.StringVar(&optMerges, "merges", "", "Look through the merge commits blamed for changed lines, which contain most\n\tmerge base tags: blame the lines again in the `first-parent` of the merges, or\n\tin all their parents.")
+	flag.BoolVar(&optReverts, "reverts", false, "Tell the affected commits that the change reverts, in part or whole: those are\n\tbackported with the commits, if at all, rather than as new edits.")
+	flag.BoolVar(&optRelated, "related", false, "Show the commits related to each affected commit, with their merge base tags:\n\tthose with the same Change-Id, those it fixes and those fixing it, as told\n\tby Fixes: trailers.")
+	flag.BoolVar(&optDetectMoves, "detect-moves", false, "Do not attribute the lines moved within the file, removed and added back\n\tverbatim: moving old code is less risky than changing it. They are shown apart.")
+	flag.IntVar(&optHistory, "history", 0, "Show the last `n` commits that touched the lines changed by each hunk, as\n\tgit log -L does, for more context than the last commit of each line.")
+	flag.IntVar(&optBlameLines, "context", 0, "Show the blame, commit and content, of the lines changed by each hunk and of\n\t`n` lines around them, to judge borderline -B and -A cases.")
+	flag.StringVar(&optCommitURL, "commit-url", "", "Show the web URL of each affected commit, given by `pattern` where %H is\n\tthe commit id, e.g. https://github.com/org/repo/commit/%H, or auto to derive it\n\tfrom the URL of the first -remote.")
+	flag.BoolVar(&optLinks, "links", false, "Show the web permalinks of the changed lines in the affected commits that last\n\tchanged them, .../blob/<commit>/<path>#L<n>, for review comments. The web URL\n\tis the one of -commit-url or of the first -remote.")
+	flag.StringVar(&optOutput, "o", "", "Write the output to `file` instead of the standard output.")
+	flag.StringVar(&optOutputDir, "output-dir", "", "Write the report of each file to its own file in `directory`, at the path of\n\tthe file in the repository with .txt added (.z with -z, .json with\n\t-json). The summary is\n\twritten to the output.")
+	flag.StringVar(&optPipe, "pipe", "", "Feed the -json output, or the -z one with -z, to the shell `command`, e.g.\n\t'jq -r .files[].file', and show its output instead.")
+	flag.BoolVar(&optJSON, "json", false, "Output a JSON report of the files instead: their lines removed and added,\n\taffected commits with their tags, branches and lines, and common tags.")
+	flag.StringVar(&optCompare, "compare", "", "Show what changed since the -json report in `file`: newly affected commits,\n\tcommon tags gained or lost and the files needing a newer base. It is shown on\n\tthe standard error with -json.")
+	flag.IntVar(&optMaxAffected, "max-affected", 0, "Fail when the change of a file affects more than `n` commits, to have\n\tsprawling changes split. 0 means no limit.")
+	flag.BoolVar(&optCommonOnly, "common-only", false, "Show only the oldest common tag of each file, as file: tag, and then the one\n\tcommon to all the files, none when there is none.")
+	flag.StringVar(&optShowTags, "show-tags", "", "Show only the merge base tags matching the glob `pattern`, e.g. MERGE_BASE_1?.\n\tAll the tags are still used to find the common ones.")
+	flag.StringVar(&optProfile, "profile", "", "Use the profile `name` of the .git-check-diff file at the top of the\n\trepository, e.g. [profile \"lts\"], for its tags, branches and other flags.")
 	flag.BoolVar(&optShowDate, "date", false, "Show commit date")
+	flag.StringVar(&optDateFormat, "date-format", "", "Show the commit dates with the Go time `layout`, e.g. 2006-01-02, or as iso,\n\trfc or unix (seconds since the epoch). Implies -date.")
 	flag.BoolVar(&optCached, "cached", false, "Pass --cached option to git diff")
-	flag.StringVar(&optHunks, "H", "", "Check the given hunks only (comma separated, first hunk is 1, from git diff -U0).")
-	flag.BoolVar(&optShowHunk, "hunk", false, "Show hunk")
+	flag.StringVar(&optHunks, "H", "", "Check the given hunks only (comma separated, first hunk is 1, as numbered\n\tby git diff -U<n>, see -U).")
+	flag.BoolVar(&optShowHunk, "hunk", false, "Show hunk, highlighted on a terminal (unless NO_COLOR is set)")
+	flag.StringVar(&optBaseRef, "base-ref", "", "Check the changes made since HEAD forked from `ref`, as in git diff ref...HEAD,\n\tinstead of the uncommitted ones. All the modified files are checked when none\n\tare given.")
+	flag.StringVar(&optRange, "range", "", "Check the changes made on `new` since it forked from old, given as old...new\n\tor old..new, as in git diff old...new. All the modified files are checked when\n\tnone are given.")
+	flag.StringVar(&optPR, "pr", "", "Check the GitHub pull request `number` (of the first -remote) or URL against\n\tits fork point from -base-ref, or the default branch, without checking it out.")
+	flag.StringVar(&optMR, "mr", "", "Check the GitLab merge request `iid` of the first -remote, as -pr does. The\n\tsummary is posted as a note of the merge request when GIT_CHECK_DIFF_GITLAB_TOKEN\n\tis set.")
+	flag.StringVar(&optChange, "change", "", "Check the Gerrit change `number[/patchset]` (latest patchset by default) of the\n\tfirst -remote. The report is posted as a review comment when\n\tGIT_CHECK_DIFF_GERRIT_USER and GIT_CHECK_DIFF_GERRIT_PASSWORD are set.")
+	flag.StringVar(&optGerritLabel, "gerrit-label", "", "Vote on the Gerrit `label` with the review comment of -change: +1 when the\n\taffected commits have a common tag (and are in -target), -1 otherwise.")
+	flag.StringVar(&optWorktree, "worktree", "", "Check the files of the linked worktree with the given `path`, directory name or\n\tbranch (see git worktree list) instead of the current one.")
+	flag.BoolVar(&optRecurseSubmodules, "recurse-submodules", false, "Check the changes inside the changed submodules as well, between their old\n\tand new commits.")
+	flag.BoolVar(&optUntracked, "untracked", false, "Also check the untracked files, as wholly new content. They are listed with\n\tthe modified files of directory and pathspec arguments.")
+	flag.DurationVar(&optGitTimeout, "git-timeout", 0, "Kill the git commands taking longer than `duration`, such as the blame of a\n\thuge file, and fail. 0 means no limit.")
+	flag.DurationVar(&optLockTimeout, "lock-timeout", 5*time.Second, "Retry the git commands failing because another git process holds a lock,\n\tsuch as index.lock, for up to `duration`.")
+	flag.StringVar(&optFilesFrom, "files-from", "", "Also check the files listed, one per line, in `file`, - for the standard\n\tinput.")
+	flag.BoolVar(&optNulData, "z", false, "Read the -files-from list NUL separated, and output one NUL terminated record\n\tper file instead of the report: lines removed, added, affected commits and\n\tcommon tags (space separated), separated by tabs, and the path.")
+	flag.BoolVar(&optUpstream, "upstream", false, "Check the changes made since HEAD forked from its upstream branch, committed\n\tor not (staged only with -cached). All the modified files are checked when\n\tnone are given.")
+	flag.IntVar(&optContext, "U", 0, "Show `n` lines of context around the changes of -hunk. Only the changed lines\n\tare blamed. Hunks closer than 2*n lines are merged, as git diff does.")
+	flag.BoolVar(&optMatrix, "matrix", false, "Show a table of files and affected commits against the branches containing them.")
+	flag.StringVar(&optDot, "dot", "", "Write a Graphviz graph of the files, affected commits, tags and branches to `file`.")
+	flag.StringVar(&optSqlite, "sqlite", "", "Append the results to the SQLite database `file` (requires sqlite3).")
+	flag.StringVar(&optNotify, "notify-url", "", "POST a JSON summary of the results to the webhook at `url` (Slack compatible).")
+	flag.StringVar(&optMailTo, "mail-to", "", "Email the report to the given comma separated `addresses`.")
+	flag.StringVar(&optMailFrom, "mail-from", "", "Sender `address` for -mail-to (default: git config user.email).")
+	flag.StringVar(&optSMTP, "smtp", "localhost:25", "SMTP server `host:port` used by -mail-to.")
+	flag.StringVar(&optNotes, "notes", "", "Attach a summary of the results as a git note (refs/notes/check-diff) on the given `commit`, e.g. HEAD.")
+	flag.BoolVar(&optTrailers, "emit-trailers", false, "Print Fixes: and Backport-to: commit message trailers for the affected commits.")
+	flag.BoolVar(&optOwners, "owners", false, "Show the CODEOWNERS of the changed files and of the files touched by the affected commits.")
+	flag.BoolVar(&optAuthor, "author", false, "Show the author of each affected commit, resolved through .mailmap.")
+	flag.BoolVar(&optByAuthor, "by-author", false, "Group the affected commits by their (mailmapped) author.")
+	flag.Var(&optExcludeAuthors, "exclude-author", "Attribute lines last changed by authors matching the `pattern` (a regular\n\texpression, as in git log --author) to the commit before. Can be repeated.")
+	flag.StringVar(&optTarget, "target", "", "Check that all the affected commits are contained in `branch` and exit with\n\tnon-zero status if they are not.")
+	flag.BoolVar(&optDistance, "distance", false, "Show how many commits and days separate each affected commit from HEAD.")
+	flag.BoolVar(&optPropagation, "propagation", false, "Show the branches the fix reaches once merged upward from the oldest branch to\n\tbackport it to, and which ones diverge until then.")
+	flag.BoolVar(&optFirstRelease, "first-release", false, "Show the first release tag (other than the merge base tags) containing each\n\taffected commit.")
+	flag.StringVar(&optReleaseTags, "release-tags", "", "Also report the release tags matching `pattern` (e.g. v*) that contain the\n\taffected commits.")
+	flag.StringVar(&optDefaultBranch, "default-branch", "", "The integration `branch` checked for containment along with the release\n\tbranches (default: each remote's HEAD, e.g. origin/develop).")
+	flag.StringVar(&optRemotes, "remote", "origin", "Comma separated `remotes` whose release and default branches are checked for\n\tcontainment.")
+	flag.StringVar(&optLocalBranches, "local-branches", "", "Also check the local branches matching `pattern` (e.g. release-*) for\n\tcontainment.")
+	flag.BoolVar(&optNoCommitGraph, "no-commit-graph", false, "Do not use the commit-graph file to check tag and branch containment.")
+	flag.BoolVar(&optUnordered, "unordered", false, "Show the result of each file as soon as it is done instead of in argument order.")
+	flag.IntVar(&optMaxProcs, "max-procs", runtime.NumCPU(), "Run at most `number` git processes at the same time. 0 means no limit.")
+	flag.StringVar(&optCPUProfile, "cpuprofile", "", "Write a CPU profile to `file`.")
+	flag.StringVar(&optMemProfile, "memprofile", "", "Write a memory profile to `file` on exit.")
+	flag.StringVar(&optExecTrace, "exec-trace", "", "Write an execution trace to `file`.")
+	flag.StringVar(&optLogLevel, "log-level", "warn", "Show the diagnostics of `level` debug, info or warn and above on the standard\n\terror.")
+	flag.BoolVar(&optTrace, "trace", false, "Show each git command run, its duration and exit status on the standard error.")
+	usage := flag.Usage
+	flag.Usage = func() {
+		usage()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nThe defaults can be set in the environment, e.g. %s=3 for -limit 3.\n",
+			envName("limit"))
+	}
+	setFlagsFromEnv()
 	flag.Parse()
+	setupLogging(optLogLevel)
+
+	startProfiling()
+
+	handleInterrupts()
+	checkRepository()
+
+	if optWorktree != "" {
+		useWorktree(optWorktree)
+		optDot = userPath(optDot)
+		optSqlite = userPath(optSqlite)
+		optMemProfile = userPath(optMemProfile)
+		optOutput = userPath(optOutput)
+		optOutputDir = userPath(optOutputDir)
+		optCompare = userPath(optCompare)
+	}
+	if optProfile != "" {
+		useProfile(optProfile)
+	}
+	if optPipe != "" && !optNulData {
+		optJSON = true
+	}
+	if optJSON && optNulData {
+		bail("error: -json cannot be used with -z")
+	}
+	var previous jsonReport
+	if optCompare != "" {
+		var err error
+		if previous, err = readJSON(optCompare); err != nil {
+			bail("error: -compare: %v", err)
+		}
+	}
+	if optOutput != "" {
+		setOutput(optOutput)
+	}
+	if optPipe != "" {
+		pipeOutput(optPipe)
+	}
 
 	if optLimit == 0 {
 		optAll = true
 	}
 
+	if optContext < 0 {
+		bail("error: -U must not be negative")
+	}
+	if optHistory < 0 {
+		bail("error: -history must not be negative")
+	}
+	if optBlameLines < 0 {
+		bail("error: -context must not be negative")
+	}
+	if optMaxAffected < 0 {
+		bail("error: -max-affected must not be negative")
+	}
+	if _, err := path.Match(optShowTags, ""); err != nil {
+		bail("error: -show-tags %s: %v", optShowTags, err)
+	}
+	checkDateFormat(optDateFormat)
+	if optDateFormat != "" {
+		optShowDate = true
+	}
+
+	if optMaxProcs > 0 {
+		procs = make(chan struct{}, optMaxProcs)
+	}
+
+	if (optBefore || optAfter) && optOffset != 0 {
+		bail("error: -offset cannot be used with -B or -A")
+	}
+	switch optNeighbor {
+	case "":
+	case "oldest", "newest", "both":
+		if optBefore || optAfter || optOffset != 0 {
+			bail("error: -neighbor cannot be used with -B, -A or -offset")
+		}
+	default:
+		bail("error: -neighbor %s: must be oldest, newest or both", optNeighbor)
+	}
+	switch optAdded {
+	case "before", "after", "both", "skip":
+	default:
+		bail("error: -added %s: must be before, after, both or skip", optAdded)
+	}
+	switch optMerges {
+	case "", "first-parent", "all":
+	default:
+		bail("error: -merges %s: must be first-parent or all", optMerges)
+	}
+	if optAddedVs != "" {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", optAddedVs+"^{commit}"); err != nil {
+			bail("error: -added-vs %s: unknown revision", optAddedVs)
+		}
+	}
 	if optBefore {
 		optOffset = -1
 	} else if optAfter {
 		optOffset = 1
 	}
 
+	if optOwners {
+		codeOwners = loadCodeOwners()
+	}
+
+	if !optNoCommitGraph {
+		commitGraph = loadCommitGraph()
+	}
+
+	remotes = strings.Split(optRemotes, ",")
+	switch {
+	case optCommitURL == "auto":
+		if optCommitURL = remoteCommitURL(remotes[0]); optCommitURL == "" {
+			bail("error: -commit-url auto: cannot tell the web URL of remote %s", remotes[0])
+		}
+	case optCommitURL != "" && !strings.Contains(optCommitURL, "%H"):
+		bail("error: -commit-url %s: the pattern has no %%H", optCommitURL)
+	}
+	if optLinks {
+		if forgeBase = linksBase(); forgeBase == "" {
+			bail("error: -links: cannot tell the web URL of remote %s, see -commit-url", remotes[0])
+		}
+	}
+	if optDefaultBranch != "" {
+		defaultBranches = []string{optDefaultBranch}
+	} else {
+		for _, remote := range remotes {
+			if b := detectDefaultBranch(remote); b != "" {
+				defaultBranches = append(defaultBranches, b)
+			}
+		}
+	}
+
+	spec := worktreeSpec()
+	// what the changes are, when they are not the uncommitted ones
+	changes := ""
+	switch {
+	case optPR != "" && optMR != "" || optChange != "" && (optPR != "" || optMR != ""):
+		bail("only one of -pr, -mr and -change can be used")
+	case optUpstream && (optBaseRef != "" || optPR != "" || optMR != "" || optChange != ""):
+		bail("-upstream cannot be used with -base-ref, -pr, -mr or -change")
+	case optCached && (optBaseRef != "" || optPR != "" || optMR != "" || optChange != ""):
+		bail("-cached cannot be used with -base-ref, -pr, -mr or -change")
+	case optRange != "" && (optCached || optUpstream || optBaseRef != "" || optPR != "" || optMR != "" || optChange != ""):
+		bail("-range cannot be used with -cached, -upstream, -base-ref, -pr, -mr or -change")
+	case optRange != "":
+		old, new := splitRange(optRange)
+		spec = baseRefSpec(old, new)
+		changes = fmt.Sprintf("in %s", optRange)
+	case optPR != "":
+		head, target := fetchPullRequest(optPR)
+		base := reviewBase(target)
+		spec = baseRefSpec(base, head)
+		changes = fmt.Sprintf("since pull request %s forked from %s", optPR, reviewBaseName(base, target))
+	case optMR != "":
+		head, target := fetchMergeRequest(optMR)
+		base := reviewBase(target)
+		spec = baseRefSpec(base, head)
+		changes = fmt.Sprintf("since merge request %s forked from %s", optMR, reviewBaseName(base, target))
+	case optChange != "":
+		spec = commitSpec(fetchChange(optChange))
+		changes = fmt.Sprintf("by change %s", optChange)
+	case optBaseRef != "":
+		spec = baseRefSpec(optBaseRef, "HEAD")
+		changes = fmt.Sprintf("since HEAD forked from %s", optBaseRef)
+	case optUpstream:
+		spec = upstreamSpec()
+		changes = "since HEAD forked from @{upstream}"
+	}
+
+	if changes == "" && unbornHead() {
+		warn("note: HEAD has no commits yet, there is nothing to blame: all the lines are new")
+	}
+
 	args := flag.Args()
+	if optFilesFrom != "" {
+		args = append(args, readFileList(optFilesFrom)...)
+	}
+	if len(args) == 0 && changes != "" {
+		args = changedFiles(spec)
+		if len(args) == 0 {
+			fmt.Printf("No files modified %s\n", changes)
+			exit(0)
+		}
+	}
+	if len(args) == 0 {
+		bail("Usage: git check-diff <file>\n       git check-diff verify-trailers <revision range>\n       git check-diff series <patch directory | patches | mbox>\n       git check-diff range-diff <old range> <new range>\n       git check-diff bundle <file>")
+	}
+
+	if args[0] == "bundle" {
+		if len(args) != 2 {
+			bail("Usage: git check-diff bundle <file>")
+		}
+		checkBundle(userPath(args[1]))
+		exit(0)
+	}
+
+	if args[0] == "range-diff" {
+		if len(args) != 3 {
+			bail("Usage: git check-diff range-diff <old range> <new range>")
+		}
+		compareRanges(args[1], args[2])
+		exit(0)
+	}
+
+	if args[0] == "series" {
+		if len(args) == 1 {
+			bail("Usage: git check-diff series <patch directory | patches | mbox>")
+		}
+		checkSeries(args[1:])
+		exit(0)
+	}
+
+	if args[0] == "verify-trailers" {
+		if len(args) == 1 {
+			bail("Usage: git check-diff verify-trailers <revision range>")
+		}
+		if !verifyTrailers(args[1:]) {
+			exit(1)
+		}
+		exit(0)
+	}
+
+	args = expandPathspecs(args, spec)
 	if len(args) == 0 {
-		bail("Usage: git check-diff <file>")
+		fmt.Printf("No modified files match %s\n", strings.Join(flag.Args(), " "))
+		exit(0)
 	}
 
 	var hunks WantedHunks
@@ -66,314 +448,1876 @@ func main() {
 			if err != nil {
 				bail("%s: %v", v, err)
 			}
+			if n < 1 {
+				bail("error: -H %d: the first hunk is 1", n)
+			}
 			hunks[n] = true
 		}
 	}
 
-	tagsSeen := map[string]int{}
-	for i, filename := range args {
-		for _, tag := range checkDiff(filename, hunks) {
-			tagsSeen[tag]++
+	projects := groupByProject(args)
+	reports := make([]*FileReport, len(args))
+	targetMissed := false
+	// a file that cannot be checked does not end a multi-file run
+	var failed []string
+	if len(args) > 1 {
+		atomic.StoreInt32(&softFailures, 1)
+	}
+	i := 0
+	results := analyzeFiles(args, hunks, spec)
+	atomic.StoreInt32(&interruptHandled, 1)
+	for {
+		var result fileResult
+		var ok bool
+		select {
+		case result, ok = <-results:
+		case <-interrupted:
+			showPartialSummary(reports)
+			exit(130)
+		}
+		if !ok {
+			break
+		}
+		report := result.report
+		err := result.err
+		if err == nil {
+			err = catchFailure(func() error {
+				show := func() {
+					switch {
+					case optJSON:
+						if optOutputDir != "" {
+							showJSON([]*FileReport{report}, report.CommonTags)
+						}
+					case optNulData:
+						showRecord(report)
+					case optCommonOnly:
+						fmt.Printf("%s: %s\n", report.File, oldestTag(report.CommonTags.shown()))
+					default:
+						showReport(report)
+					}
+					if optTarget != "" && !checkTarget(report, optTarget) {
+						targetMissed = true
+					}
+				}
+				if optOutputDir != "" {
+					ext := ".txt"
+					switch {
+					case optJSON:
+						ext = ".json"
+					case optNulData:
+						ext = ".z"
+					}
+					return writeReport(optOutputDir, report.File, ext, show)
+				}
+				show()
+				return nil
+			})
+		}
+		if err != nil {
+			file := args[result.index]
+			warn("error: %s: %s", file, strings.TrimPrefix(err.Error(), "error: "))
+			failed = append(failed, file)
+		} else {
+			reports[result.index] = report
+		}
+		if optNulData || optJSON || optCommonOnly || optOutputDir != "" {
+			continue
 		}
 		if i > 0 && i < len(args)-1 {
 			fmt.Println()
 		}
+		i++
 	}
+	atomic.StoreInt32(&interruptHandled, 0)
+	atomic.StoreInt32(&softFailures, 0)
+	reports = checkedReports(reports)
 
-	var commonTags MergeBaseTags
-	for tag, count := range tagsSeen {
-		if count == len(args) {
-			commonTags = append(commonTags, tag)
+	commonTags := commonTagsOf(reports)
+	switch {
+	case optJSON:
+		showJSON(reports, commonTags)
+	case optNulData:
+		// the records are the whole output
+	case optCommonOnly:
+		if len(reports) > 1 {
+			fmt.Printf("%s\n", oldestTag(commonTags.shown()))
 		}
-	}
-	if len(args) > 1 {
+	case projects > 1:
+		// each project has its own merge base tags
+		names, grouped := reportsByProject(reports)
+		for _, p := range names {
+			fmt.Println()
+			fmt.Printf("Project %s:\n", p.Name())
+			showSummary("", grouped[p.Dir], commonTagsOf(grouped[p.Dir]))
+		}
+	case len(reports) > 1:
 		fmt.Println()
-		if len(commonTags) > 0 {
-			sort.Sort(commonTags)
-			fmt.Printf("COMMON TAG: %s\n", commonTags)
+		showSummary("", reports, commonTags)
+	}
+
+	if optCompare != "" {
+		w := os.Stdout
+		if optJSON {
+			w = os.Stderr
 		} else {
-			fmt.Printf("NO COMMON TAG\n")
+			fmt.Println()
 		}
+		compareReports(w, optCompare, previous, toJSON(reports, commonTags))
 	}
 
-}
-
-type MergeBaseTags []string
-
-func (m MergeBaseTags) Len() int           { return len(m) }
-func (m MergeBaseTags) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
-func (m MergeBaseTags) Less(i, j int) bool { return getTagNumber(m[i]) < getTagNumber(m[j]) }
+	if optMatrix {
+		fmt.Println()
+		showMatrix(reports)
+	}
 
-func (m MergeBaseTags) String() string {
-	b := &bytes.Buffer{}
-	for i, tag := range m {
-		fmt.Fprintf(b, "%s ", tag)
-		if !optAll && optLimit > 0 && i+1 >= optLimit && i < len(m)-1 {
-			fmt.Fprintf(b, "... %d more (use -all to show all)", len(m)-(i+1))
-			break
+	if optDot != "" {
+		if err := writeDot(optDot, reports); err != nil {
+			bail("error: %v", err)
 		}
 	}
-	return b.String()
-}
 
-func getTagNumber(mbtag string) int {
-	if !strings.HasPrefix(mbtag, "MERGE_BASE_") {
-		panic(fmt.Sprintf("%s is not a MERGE_BASE tag", mbtag))
-	}
-	chunks := strings.Split(mbtag, "_")
-	if len(chunks) != 3 {
-		panic(fmt.Sprintf("%s do not match MERGE_BASE_N pattern", mbtag))
+	if optSqlite != "" {
+		if err := writeSqlite(optSqlite, reports); err != nil {
+			bail("error: %v", err)
+		}
 	}
 
-	n, err := strconv.Atoi(chunks[2])
-	if err != nil {
-		panic(fmt.Sprintf("%s: %v", mbtag, err))
+	if optNotify != "" {
+		if err := notify(optNotify, reports, commonTags); err != nil {
+			bail("error: %v", err)
+		}
 	}
-	return n
-}
 
-var (
-	HUNK_PREFIX = []byte{'@', '@', ' ', '-'}
-	SPACE       = []byte{' '}
-	COMMA       = []byte{','}
-)
+	if optMailTo != "" {
+		from := optMailFrom
+		if from == "" {
+			out, _ := gitOutput("config", "user.email")
+			if from = strings.TrimSpace(string(out)); from == "" {
+				bail("error: -mail-to: no sender address, set user.email or pass -mail-from")
+			}
+		}
+		to := strings.Split(optMailTo, ",")
+		if err := mailReport(optSMTP, from, to, reports, commonTags); err != nil {
+			bail("error: %v", err)
+		}
+	}
 
-func checkDiff(file string, hunks WantedHunks) MergeBaseTags {
-	var commonTags MergeBaseTags
-	fmt.Printf("%s\n", file)
-	blame := getBlame(file)
-	commitsAffected := map[string]MergeBaseTags{}
+	if optNotes != "" {
+		addNote(optNotes, reports, commonTags)
+	}
 
-	linesForCommit := map[string][]int{}
-	gitDiffArgs := []string{"diff", "-U0"}
-	if optCached {
-		gitDiffArgs = append(gitDiffArgs, "--cached")
+	if optTrailers {
+		fmt.Println()
+		emitTrailers(reports)
 	}
-	gitDiffArgs = append(gitDiffArgs, "--", file)
 
-	buf, err := exec.Command("git", gitDiffArgs...).Output()
-	if err != nil {
-		bail("error: %v", err)
+	if user := os.Getenv("GIT_CHECK_DIFF_GERRIT_USER"); optChange != "" && user != "" {
+		err := postChangeReview(optChange, user, os.Getenv("GIT_CHECK_DIFF_GERRIT_PASSWORD"),
+			reports, commonTags, len(commonTags) > 0 && !targetMissed)
+		if err != nil {
+			bail("error: %v", err)
+		}
 	}
-	diff, err := NewDiff(bytes.NewReader(buf))
-	if err != nil {
-		bail("error: %v", err)
+
+	if token := os.Getenv("GIT_CHECK_DIFF_GITLAB_TOKEN"); optMR != "" && token != "" {
+		if err := postMergeRequestNote(optMR, token, reports, commonTags); err != nil {
+			bail("error: %v", err)
+		}
 	}
 
-	if hunks != nil {
-		odiff := diff
-		diff = Diff{}
-		for i, hunk := range odiff.Hunks {
-			if !hunks[i+1] {
-				continue
+	if len(failed) > 0 {
+		warn("\nerror: %d of %d files could not be checked: %s",
+			len(failed), len(args), strings.Join(failed, ", "))
+		exit(1)
+	}
+	if optMaxAffected > 0 {
+		sprawling := false
+		for _, r := range reports {
+			if n := len(r.Commits); n > optMaxAffected {
+				warn("error: %s: the change affects %d commits, more than %d, split it", r.File, n, optMaxAffected)
+				sprawling = true
 			}
-			diff.Added += hunk.Added.Count
-			diff.Removed += hunk.Removed.Count
-			diff.Hunks = append(diff.Hunks, hunk)
 		}
+		if sprawling {
+			exit(1)
+		}
+	}
+	if targetMissed {
+		exit(1)
 	}
+	exit(0)
+}
 
-	fmt.Printf("    Lines: %d removed, %d added\n", diff.Removed, diff.Added)
-	for _, hunk := range diff.Hunks {
-		if optShowHunk {
-			fmt.Printf("%s\n", hunk.diff)
-		}
-		if hunk.Removed.Count == 0 {
-			// no lines removed, just new lines added
+type MergeBaseTags []string
 
-			// verify that new lines are added
-			if hunk.Added.Count == 0 {
-				bail("FIXME expecting added hunk count greater than 0 but got %d", hunk.Added.Count)
-			}
+func (m MergeBaseTags) Len() int      { return len(m) }
+func (m MergeBaseTags) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m MergeBaseTags) Less(i, j int) bool {
+	return versionLess(m[i], m[j])
+}
 
-			lnum := hunk.Removed.Start
-			if lnum == 0 {
-				lnum = 1
-			}
-			sha1 := blame.sha1(lnum)
-			if len(sha1) == 0 {
-				continue
-			}
-			commitsAffected[sha1] = nil
-			linesForCommit[sha1] = append(linesForCommit[sha1], lnum)
-		} else {
-			from := hunk.Removed.Start
-			count := hunk.Removed.Count
-			if count > 1 {
-				for lnum := from; lnum < from+count; lnum++ {
-					lnum := lnum + optOffset
-					if lnum > 0 && lnum < len(blame) {
-						sha1 := blame.sha1(lnum)
-						if len(sha1) == 0 {
-							continue
-						}
-						commitsAffected[sha1] = nil
-						linesForCommit[sha1] = append(linesForCommit[sha1], lnum)
-					} else {
-						fmt.Printf("DEBUG out of bound len(blame) = %d, lnum %d\n", len(blame), lnum)
-					}
-				}
-			} else {
-				lnum := from + optOffset
-				sha1 := blame.sha1(lnum)
-				if len(sha1) == 0 {
-					continue
-				}
-				commitsAffected[sha1] = nil
-				linesForCommit[sha1] = append(linesForCommit[sha1], lnum)
-			}
+func (m MergeBaseTags) String() string {
+	return limitedList(m.shown())
+}
+
+// shown returns the tags matching -show-tags.
+func (m MergeBaseTags) shown() MergeBaseTags {
+	if optShowTags == "" {
+		return m
+	}
+	var shown MergeBaseTags
+	for _, tag := range m {
+		if ok, _ := path.Match(optShowTags, tag); ok {
+			shown = append(shown, tag)
 		}
 	}
-	tagsSeen := map[string]int{}
-	nCommits := len(commitsAffected)
-	for sha1, _ := range commitsAffected {
-		tags := findMergeBaseTags(sha1)
-		commitsAffected[sha1] = tags
-		for _, tag := range tags {
-			tagsSeen[tag]++
+	return shown
+}
+
+// ReleaseTags are the tags matching -release-tags, in version order.
+type ReleaseTags []string
+
+func (r ReleaseTags) String() string {
+	return limitedList(r)
+}
+
+// limitedList joins the items, showing no more than -limit of them.
+func limitedList(items []string) string {
+	b := &bytes.Buffer{}
+	for i, item := range items {
+		fmt.Fprintf(b, "%s ", item)
+		if !optAll && optLimit > 0 && i+1 >= optLimit && i < len(items)-1 {
+			fmt.Fprintf(b, "... %d more (use -all to show all)", len(items)-(i+1))
+			break
 		}
-		//fmt.Printf("\t%s %s\n", sha1, getAffectedBranches(sha1))
+	}
+	return b.String()
+}
 
+// limitedBranches joins the branches, up to the -limit number of them.
+func limitedBranches(branches []string) string {
+	if optAll || optLimit <= 0 || len(branches) <= optLimit {
+		return strings.Join(branches, ", ")
 	}
+	return fmt.Sprintf("%s, ... %d more (use -all to show all)",
+		strings.Join(branches[:optLimit], ", "), len(branches)-optLimit)
+}
 
-	hotTags := []string{}
-	var tags MergeBaseTags
-	for tag, count := range tagsSeen {
-		if count == nCommits {
-			tags = append(tags, tag)
-		} else if count > 1 {
-			hotTags = append(hotTags, tag)
+// showTagCommits prints the commit each of the tags points to, and the
+// number of commits between consecutive tags, up to the -limit number of
+// tags.
+func showTagCommits(tags MergeBaseTags, indent string) {
+	tags = tags.shown()
+	for i, tag := range tags {
+		if !optAll && optLimit > 0 && i >= optLimit {
+			break
 		}
+		l := linesFrom("git", "show", "--no-patch", "--date=short", "--format=%h %ad %s", tag+"^{commit}")
+		fmt.Printf("%s%s: %s", indent, tag, l[0])
+		if i > 0 {
+			count := linesFrom("git", "rev-list", "--count", tags[i-1]+".."+tag)
+			fmt.Printf(" (+%s commits since %s)", count[0], tags[i-1])
+		}
+		fmt.Println()
 	}
+}
 
-	if len(tags) > 0 {
-		// We have a common commit for all the affected commits
-		fmt.Printf("    Commits affected:\n")
-		// TODO when showing affected commits, sort them by their line numbers
-		for sha1, _ := range commitsAffected {
-			showCommit(sha1)
-			if optShowLine {
-				showLines(linesForCommit[sha1])
-			}
-		}
-		fmt.Printf("    Common tag:\n")
-		sort.Sort(tags)
-		fmt.Printf("\t%s\n", tags)
-		commonTags = tags
-	} else {
-		// print relevant tags for this sha1
-		fmt.Printf("    No common tags found for all the affected commits.\n")
-		for sha1, tags := range commitsAffected {
-			showCommit(sha1)
-			fmt.Printf("\t\t")
-			sort.Sort(tags)
-			tagsToShow := &bytes.Buffer{}
-			for _, tag := range tags {
-				if tagsSeen[tag] > 1 {
-					fmt.Fprintf(tagsToShow, "%s ", tag)
+// getTagNumber returns N for a merge base tag named <prefix>_N, such as
+// MERGE_BASE_N, or <prefix>-N. ok is false for the tags of other patterns,
+// such as v1.1, which the -tags setting of a project may select.
+func getTagNumber(mbtag string) (n int, ok bool) {
+	i := strings.LastIndexAny(mbtag, "_-")
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(mbtag[i+1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// versionLess orders the tags as versions: their runs of digits compare as
+// numbers, so that MERGE_BASE_9 comes before MERGE_BASE_10 and v1.9 before
+// v1.10.
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := isDigit(a[0]), isDigit(b[0])
+		if da && db {
+			na, ra := leadingNumber(a)
+			nb, rb := leadingNumber(b)
+			if na != nb {
+				if len(na) != len(nb) {
+					return len(na) < len(nb)
 				}
+				return na < nb
 			}
-			if tagsToShow.Len() > 0 {
-				fmt.Printf("%s\n", tagsToShow)
-			}
-			showLines(linesForCommit[sha1])
+			a, b = ra, rb
+			continue
 		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
 	}
+	return len(a) < len(b)
+}
 
-	return commonTags
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
 }
 
-func showCommit(sha1 string) {
-	fmt.Printf("\t%s", sha1)
-	if optShowDate {
-		fmt.Printf(" %s", getCommitDate(sha1))
+// leadingNumber splits the digits s starts with, without leading zeros,
+// from the rest of s.
+func leadingNumber(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
 	}
-	fmt.Printf(" %s\n", getAffectedBranches(sha1))
+	return strings.TrimLeft(s[:i], "0"), s[i:]
 }
 
-func getCommitDate(ref string) time.Time {
-	l := linesFrom("git", "show", "--no-patch", "--format=%at", ref)
-	date := string(l[0])
-	n, err := strconv.Atoi(date)
-	if err != nil {
-		log.Panicf("error parsing commit date %s: %v", date, err)
+// showPartialSummary shows the summary of the files checked before an
+// interrupt.
+func showPartialSummary(reports []*FileReport) {
+	done := checkedReports(reports)
+	warn("\ninterrupted, %d of %d files checked", len(done), len(reports))
+	if len(done) > 1 && !optNulData {
+		fmt.Println()
+		showSummary("", done, commonTagsOf(done))
 	}
-	return time.Unix(int64(n), 0)
 }
 
-func showLines(lnums []int) {
-	lines := &bytes.Buffer{}
-	for _, lnum := range lnums {
-		fmt.Fprintf(lines, "%d ", lnum)
-	}
-	if lines.Len() > 0 {
-		fmt.Printf("\tlines: %s\n", lines)
+// oldestTag returns the oldest of the common tags, "none" when there are
+// none.
+func oldestTag(tags MergeBaseTags) string {
+	if len(tags) == 0 {
+		return "none"
 	}
+	return tags[0]
 }
-func getAffectedBranches(sha1 string) string {
-	var branches []string
-	for _, b := range linesFrom("git", "branch", "--list", "--all", "--contains", sha1, "origin/release-*", "origin/develop") {
-		b = bytes.TrimLeft(b, " *")
-		branch := strings.TrimPrefix(string(b), "remotes/")
-		switch {
-		case branch == "origin/develop":
-			branches = append(branches, branch)
-		case strings.HasPrefix(branch, "origin/release-"):
-			branches = append(branches, branch)
+
+// checkedReports returns the reports of the files checked, leaving out the
+// ones still unchecked or that failed.
+func checkedReports(reports []*FileReport) []*FileReport {
+	var checked []*FileReport
+	for _, r := range reports {
+		if r != nil {
+			checked = append(checked, r)
 		}
 	}
-	return "(" + strings.Join(branches, ", ") + ")"
+	return checked
 }
 
-func findMergeBaseTags(sha1 string) []string {
-	var tags []string
-	for _, line := range linesFrom("git", "tag", "--contains", sha1, "-l", "MERGE_BASE_*") {
-		if len(line) > 0 {
-			tags = append(tags, string(line))
+// showSummary shows the merge base tags common to all the reports, or the
+// tags covering their affected commits, and where to backport the change.
+func showSummary(what string, reports []*FileReport, commonTags MergeBaseTags) {
+	if len(commonTags) > 0 {
+		fmt.Printf("%sCOMMON TAG: %s\n", what, commonTags)
+		showTagCommits(commonTags, "\t")
+	} else {
+		fmt.Printf("NO %sCOMMON TAG\n", what)
+		allCommits := map[string]MergeBaseTags{}
+		for _, r := range reports {
+			for sha1, tags := range r.Commits {
+				allCommits[sha1] = tags
+			}
+		}
+		showTagCover(allCommits, "")
+	}
+	allBranches := map[string][]string{}
+	for _, r := range reports {
+		for sha1, branches := range r.Branches {
+			allBranches[sha1] = branches
 		}
 	}
-	return tags
+	showBackportBranches(allBranches, "")
+	if len(allBranches) > 0 && reports[0].Project != nil {
+		showSuggestion(commonTags, reports[0].Project.Branches, "")
+	}
+	if optPropagation && len(reports) > 0 && reports[0].Project != nil {
+		showPropagation(allBranches, reports[0].Project.Branches, "")
+	}
 }
 
-func asInt(buf []byte) int {
-	n, err := strconv.Atoi(string(buf))
-	if err != nil {
-		bail("%s: %v", buf, err)
+// commonTagsOf returns the merge base tags common to all the reports with
+// affected commits: new files, submodules and mode changes have none.
+func commonTagsOf(reports []*FileReport) MergeBaseTags {
+	tagsSeen := map[string]int{}
+	n := 0
+	for _, r := range reports {
+		if len(r.Commits) == 0 {
+			continue
+		}
+		n++
+		for _, tag := range r.CommonTags {
+			tagsSeen[tag]++
+		}
 	}
-	return n
+	var commonTags MergeBaseTags
+	for tag, count := range tagsSeen {
+		if count == n {
+			commonTags = append(commonTags, tag)
+		}
+	}
+	sort.Sort(commonTags)
+	return commonTags
 }
 
-type LineBlame []byte
+// FileReport is the result of checking the changes made to a single file.
+type FileReport struct {
+	File string
+	// Project the file belongs to
+	Project *Project
+	// Whether the file is new, added or untracked, with no lines to blame
+	New  bool
+	Diff unidiff.Diff
+	// Merge base tags for each affected commit
+	Commits map[string]MergeBaseTags
+	// Branches containing each affected commit
+	Branches map[string][]string
+	// Issue references in the message of each affected commit
+	Issues map[string][]string
+	// Author of each affected commit, set with -author or -by-author
+	Authors map[string]string
+	// First release tag containing each affected commit, set with -first-release
+	FirstRelease map[string]string
+	// Distance of each affected commit from HEAD, set with -distance
+	Distance map[string]Distance
+	// Release tags containing each affected commit and all of them, set with
+	// -release-tags
+	ReleaseTags       map[string]ReleaseTags
+	CommonReleaseTags ReleaseTags
+	// Changed line numbers for each affected commit
+	Lines map[string][]int
+	// Numbers of the changed lines in the file of each affected commit,
+	// and the path of that file, with -links
+	Sources     map[string][]int
+	SourcePaths map[string]string
+	CommonTags  MergeBaseTags
+	// Owners of the file and of the files touched by the affected commits
+	Owners       []string
+	CommitOwners []string
+	// Change of the commit of the submodule, when the file is one
+	Submodule *SubmoduleChange
+	// Change of the target of the symbolic link, when the file is one
+	Symlink *SymlinkChange
+	// Added lines already in the file on the -added-vs branch
+	AddedOnRef []RefLine
+	// Affected commits that the change reverts, with -reverts
+	Reverts []Revert
+	// Commits related to each affected commit, with -related
+	Related map[string][]RelatedCommit
+	// Lines moved within the file for each commit they come from, with
+	// -detect-moves
+	Moved map[string][]int
+	// Last commits of the lines changed by each hunk, with -history
+	History []LineHistory
+	// Blame of the lines around each hunk, with -context
+	BlameContext []BlameContext
+	// Whether the file has no changes, and the hint on where they are
+	// instead, as "did you mean -cached?"
+	Unchanged     bool
+	UnchangedHint string
+	// Branches holding each affected commit that no checked branch contains
+	Unmerged map[string][]string
+}
 
-func (lb LineBlame) sha1() string {
-	i := bytes.Index(lb, []byte{' '})
-	if i < 0 {
-		return ""
+// sortedCommits returns the affected commits of the report in hash order.
+func (r *FileReport) sortedCommits() []string {
+	var commits []string
+	for sha1 := range r.Commits {
+		commits = append(commits, sha1)
+	}
+	sort.Strings(commits)
+	return commits
+}
+
+// joinInts joins the numbers with spaces.
+func joinInts(nums []int) string {
+	var s []string
+	for _, n := range nums {
+		s = append(s, fmt.Sprint(n))
 	}
-	return string(lb[0:i])
+	return strings.Join(s, " ")
 }
 
-type Blame []LineBlame
+// DiffSpec selects the changes that are checked.
+type DiffSpec struct {
+	// Revisions given to git diff, none for the working tree
+	Revs []string
+	// Revision in which the removed lines are blamed
+	BlameRev string
+	// Revisions in which the lines removed from each parent of a combined
+	// diff are blamed
+	ParentRevs []string
+	// Commits looked through when blaming, the lines they still own are
+	// not reported
+	IgnoreRevs []string
+}
 
-func getBlame(file string) Blame {
-	blame := Blame{[]byte("NIL")}
-	for _, line := range linesFrom("git", "blame", "-l", "--root", "-r", "HEAD", file) {
-		lblame := LineBlame(line)
-		blame = append(blame, lblame)
+// worktreeSpec selects the uncommitted changes, or the staged ones with
+// -cached. While a merge, cherry-pick or rebase stops on conflicts, git
+// diff shows the conflicted files as combined diffs against HEAD and the
+// commit being merged.
+func worktreeSpec() DiffSpec {
+	spec := DiffSpec{BlameRev: "HEAD"}
+	if optCached {
+		spec.Revs = []string{"--cached"}
 	}
-	return blame
+	if theirs := mergingRev(); theirs != "" {
+		spec.ParentRevs = []string{"HEAD", theirs}
+	}
+	return spec
 }
 
-func (b Blame) sha1(lnum int) string {
-	return b[lnum].sha1()
+// baseRefSpec selects the changes made on head since it forked from ref,
+// like git diff ref...head.
+func baseRefSpec(ref, head string) DiffSpec {
+	base := forkPoint(ref, head)
+	return DiffSpec{Revs: []string{base, head}, BlameRev: base}
 }
 
-func linesFrom(command string, arg ...string) [][]byte {
-	return bytes.Split(run(command, arg...), []byte{'\n'})
+// worktree tells whether spec selects the changes of the work tree, those
+// git diff shows without arguments.
+func (s DiffSpec) worktree() bool {
+	return len(s.Revs) == 0
+}
+
+// gitDiff runs git diff with the arguments and parses its output, retrying
+// while git fails on a lock file, see -lock-timeout.
+func gitDiff(file string, args []string, noIndex bool) unidiff.Diff {
+	retry := newLockRetry()
+	for {
+		acquireProc()
+		cmd, done := command("git", args...)
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			bail("error: %v", err)
+		}
+		if err := cmd.Start(); err != nil {
+			bail("error: %v", err)
+		}
+		diff, perr := unidiff.Parse(stdout, optShowHunk || optAddedVs != "" || optReverts || optDetectMoves)
+		io.Copy(ioutil.Discard, stdout) // what is left after a parse error
+		err = done(cmd.Wait())
+		releaseProc()
+		if err != nil && !(noIndex && exitCode(err) == 1) {
+			// git diff --no-index exits with 1 when the files differ
+			if retry.again(stderr.Bytes()) {
+				continue
+			}
+			os.Stderr.Write(stderr.Bytes())
+			bail("error: %v", err)
+		}
+		os.Stderr.Write(stderr.Bytes())
+		if perr != nil {
+			bail("error: git diff of %s: %v", file, perr)
+		}
+		return diff
+	}
+}
+
+// splitRange splits a old...new or old..new revision range.
+func splitRange(r string) (old, new string) {
+	sep := "..."
+	if !strings.Contains(r, sep) {
+		sep = ".."
+	}
+	i := strings.Index(r, sep)
+	if i <= 0 || i+len(sep) == len(r) {
+		bail("error: %s: not a range of the form old...new", r)
+	}
+	return r[:i], r[i+len(sep):]
+}
+
+// commitSpec selects the changes made by the commit.
+func commitSpec(commit string) DiffSpec {
+	parent := revParse(commit + "^")
+	return DiffSpec{Revs: []string{parent, commit}, BlameRev: parent}
+}
+
+// upstreamSpec selects the changes made since HEAD forked from its
+// upstream branch, committed or not: all the unpushed work.
+func upstreamSpec() DiffSpec {
+	if _, err := gitOutput("rev-parse", "-q", "--verify", "@{upstream}"); err != nil {
+		switch {
+		case unbornHead():
+			bail("error: HEAD has no commits yet, there is nothing to compare with @{upstream}")
+		case detachedHead():
+			bail("error: HEAD is detached, it has no upstream branch")
+		}
+		bail("error: the current branch has no upstream branch")
+	}
+	base := forkPoint("@{upstream}", "HEAD")
+	spec := DiffSpec{Revs: []string{base}, BlameRev: base}
+	if optCached {
+		spec.Revs = []string{"--cached", base}
+	}
+	return spec
+}
+
+// checkRepository fails with a clear error when not run in a git
+// repository, before any other git command does.
+func checkRepository() {
+	if _, err := gitOutput("rev-parse", "--git-dir"); err != nil {
+		dir, _ := os.Getwd()
+		bail("error: not a git repository: %s", dir)
+	}
+}
+
+// unbornHead tells whether HEAD is a branch without commits yet, as in a
+// new repository.
+func unbornHead() bool {
+	_, err := gitOutput("rev-parse", "-q", "--verify", "HEAD")
+	return err != nil
+}
+
+// detachedHead tells whether HEAD is a commit rather than a branch.
+func detachedHead() bool {
+	_, err := gitOutput("symbolic-ref", "-q", "HEAD")
+	return err != nil
+}
+
+// forkPoint returns the merge base of ref and head.
+func forkPoint(ref, head string) string {
+	out, err := gitOutput("merge-base", ref, head)
+	if err != nil {
+		if head == "HEAD" && unbornHead() {
+			bail("error: HEAD has no commits yet, there is nothing to compare with %s", ref)
+		}
+		bail("error: no merge base between %s and %s", ref, head)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// changedFiles returns the files modified or deleted by the changes of
+// spec, the ones that have lines to blame, limited to the pathspecs if any
+// are given. The paths are relative to the current directory.
+func changedFiles(spec DiffSpec, pathspecs ...string) []string {
+	filter := "--diff-filter=MD"
+	if spec.worktree() {
+		// the files added with git add -N
+		filter = "--diff-filter=AMD"
+	}
+	args := diffArgs("-z", "--name-only", "--no-renames", filter)
+	args = append(append(args, spec.Revs...), "--")
+	prefix := strings.TrimSpace(string(run("git", "rev-parse", "--show-prefix")))
+	var files []string
+	for _, file := range bytes.Split(run("git", append(args, pathspecs...)...), []byte{0}) {
+		if len(file) > 0 {
+			files = append(files, relPath(prefix, string(file)))
+		}
+	}
+	if optUntracked && spec.worktree() {
+		args := append([]string{"ls-files", "-z", "--others", "--exclude-standard", "--"}, pathspecs...)
+		for _, file := range bytes.Split(run("git", args...), []byte{0}) {
+			if len(file) > 0 {
+				files = append(files, string(file))
+			}
+		}
+	}
+	return files
+}
+
+// inRevision tells whether the file exists in the revision.
+func inRevision(rev, file string) bool {
+	_, err := gitOutput("cat-file", "-e", rev+":./"+filepath.ToSlash(file))
+	return err == nil
+}
+
+// isTracked tells whether the file is in the index.
+func isTracked(file string) bool {
+	_, err := gitOutput("ls-files", "--error-unmatch", "--", file)
+	return err == nil
+}
+
+// exitCode returns the exit status of the command that failed with err, -1
+// if it did not run.
+func exitCode(err error) int {
+	if e, ok := err.(*exec.ExitError); ok {
+		return e.ExitCode()
+	}
+	return -1
+}
+
+// relPath returns the path, relative to the top of the repository, relative
+// to the directory prefix instead.
+func relPath(prefix, file string) string {
+	if prefix == "" {
+		return file
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(file))
+	if err != nil {
+		return file
+	}
+	return rel
+}
+
+// expandPathspecs replaces the directories and the arguments that are not
+// files but git pathspecs, such as 'src/**/*.go' or ':(exclude)vendor/',
+// with the files they match among the ones changed by spec. The exclusions apply to the
+// other pathspecs, or to all the changed files when there are only
+// exclusions.
+func expandPathspecs(args []string, spec DiffSpec) []string {
+	var files, pathspecs, excludes []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil && !fi.IsDir() || err != nil && !isPathspec(arg) {
+			files = append(files, arg)
+			continue
+		}
+		if isExcludePathspec(arg) {
+			excludes = append(excludes, arg)
+		} else {
+			pathspecs = append(pathspecs, arg)
+		}
+	}
+	if len(excludes) > 0 && len(pathspecs) == 0 && len(files) == 0 {
+		pathspecs = append(pathspecs, ".")
+	}
+	if len(pathspecs) == 0 {
+		return files
+	}
+	matched := changedFiles(spec, append(pathspecs, excludes...)...)
+	if len(matched) == 0 && len(files) > 0 {
+		warn("warning: no modified files match %s", strings.Join(append(pathspecs, excludes...), " "))
+	}
+	return append(files, matched...)
+}
+
+// isPathspec tells whether arg uses the git pathspec magic or wildcards.
+func isPathspec(arg string) bool {
+	return strings.HasPrefix(arg, ":") || strings.ContainsAny(arg, "*?[")
+}
+
+// isExcludePathspec tells whether arg is a pathspec with the exclude magic,
+// in its short (:! or :^) or long (:(exclude)) form.
+func isExcludePathspec(arg string) bool {
+	if strings.HasPrefix(arg, ":!") || strings.HasPrefix(arg, ":^") {
+		return true
+	}
+	if !strings.HasPrefix(arg, ":(") || !strings.Contains(arg, ")") {
+		return false
+	}
+	for _, magic := range strings.Split(arg[2:strings.Index(arg, ")")], ",") {
+		if magic == "exclude" {
+			return true
+		}
+	}
+	return false
+}
+
+// readFileList returns the paths listed in the file, or the standard input
+// for -, one per line or NUL separated with -z. Empty lines are ignored.
+func readFileList(name string) []string {
+	var buf []byte
+	var err error
+	if name == "-" {
+		buf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		buf, err = ioutil.ReadFile(userPath(name))
+	}
+	if err != nil {
+		bail("error: %v", err)
+	}
+	sep := "\n"
+	if optNulData {
+		sep = "\x00"
+	} else {
+		buf = bytes.Replace(buf, []byte("\r\n"), []byte("\n"), -1)
+	}
+	var files []string
+	for _, line := range strings.Split(string(buf), sep) {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+var (
+	mergingRevOnce sync.Once
+	mergingRevName string
+)
+
+// mergingRev returns the commit being merged, cherry-picked or rebased
+// when the operation stopped on conflicts, "" otherwise.
+func mergingRev() string {
+	mergingRevOnce.Do(func() {
+		for _, ref := range []string{"MERGE_HEAD", "CHERRY_PICK_HEAD", "REBASE_HEAD"} {
+			if _, err := gitOutput("rev-parse", "-q", "--verify", ref); err == nil {
+				mergingRevName = ref
+				return
+			}
+		}
+	})
+	return mergingRevName
+}
+
+type fileResult struct {
+	index  int
+	report *FileReport
+	// failure to check the file, with soft failures
+	err error
+}
+
+// analyzeFiles analyzes the files concurrently and sends each result as
+// soon as it is available: in argument order, or in order of completion
+// with -unordered.
+func analyzeFiles(files []string, hunks WantedHunks, spec DiffSpec) <-chan fileResult {
+	done := make(chan fileResult)
+	go func() {
+		sem := make(chan struct{}, runtime.NumCPU())
+		var wg sync.WaitGroup
+		for i, file := range files {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, file string) {
+				defer wg.Done()
+				var report *FileReport
+				err := catchFailure(func() error {
+					report = analyzeFile(file, hunks, spec)
+					return nil
+				})
+				done <- fileResult{i, report, err}
+				<-sem
+			}(i, file)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	if optUnordered {
+		return done
+	}
+
+	ordered := make(chan fileResult)
+	go func() {
+		pending := map[int]fileResult{}
+		next := 0
+		for result := range done {
+			pending[result.index] = result
+			for {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				ordered <- result
+				delete(pending, next)
+				next++
+			}
+		}
+		close(ordered)
+	}()
+	return ordered
+}
+
+// insertionLines returns the lines whose commits -added uses for lines
+// added after the line lnum, the one after it when there is none before it
+// and the other way around.
+func insertionLines(blame Blame, lnum int) []int {
+	before, after := lnum, lnum+1
+	switch {
+	case optAdded == "skip":
+		return nil
+	case before < 1:
+		return []int{after}
+	case after >= len(blame):
+		return []int{before}
+	case optAdded == "after":
+		return []int{after}
+	case optAdded == "both":
+		return []int{before, after}
+	}
+	return []int{before}
+}
+
+// unchanged tells whether git diff with the revisions shows no changes to
+// the file, not even binary ones.
+func unchanged(file string, revs []string) bool {
+	args := diffArgs("--quiet")
+	args = append(append(args, revs...), "--", file)
+	_, err := gitOutput(args...)
+	return err == nil
+}
+
+// changesElsewhere returns the hint for a file without uncommitted changes
+// when it has staged ones, or the other way around with -cached.
+func changesElsewhere(file string, spec DiffSpec) string {
+	switch {
+	case spec.worktree() && !unchanged(file, []string{"--cached"}):
+		return "did you mean -cached?"
+	case len(spec.Revs) == 1 && spec.Revs[0] == "--cached" && !unchanged(file, nil):
+		return "did you mean without -cached?"
+	}
+	return ""
+}
+
+// offsetLine returns the line -offset (or -B, -A) lines away from the
+// removed line lnum, clamped to the lines of the file with a warning when
+// it goes past the first or the last one.
+func offsetLine(file string, blame Blame, lnum int) int {
+	n := lnum + optOffset
+	switch {
+	case optOffset == 0 || len(blame) < 2:
+		return lnum
+	case n < 1:
+		warn("warning: %s:%d: %s goes before the first line, using line 1", file, lnum, offsetFlag())
+		return 1
+	case n >= len(blame):
+		last := len(blame) - 1
+		warn("warning: %s:%d: %s goes past the last line, using line %d", file, lnum, offsetFlag(), last)
+		return last
+	}
+	return n
+}
+
+// offsetFlag returns the flag setting -offset, for the messages.
+func offsetFlag() string {
+	switch {
+	case optBefore:
+		return "-B"
+	case optAfter:
+		return "-A"
+	}
+	return fmt.Sprintf("-offset %d", optOffset)
+}
+
+// neighborLines returns the lines around the removed line whose commits
+// -neighbor uses: the preceding and following ones, or the one of the two
+// last changed by the oldest or newest commit.
+func neighborLines(blame Blame, lnum int) []int {
+	before, after := lnum-1, lnum+1
+	switch {
+	case before < 1:
+		return []int{after}
+	case after >= len(blame):
+		return []int{before}
+	case optNeighbor == "both":
+		return []int{before, after}
+	}
+	b, a := blame.sha1(before), blame.sha1(after)
+	switch {
+	case b == a:
+		return []int{before}
+	case b == "" || a == "":
+		// the blame of the other one only
+		return []int{before, after}
+	}
+	older := !getCommitDate(a).Before(getCommitDate(b))
+	if older == (optNeighbor == "oldest") {
+		return []int{before}
+	}
+	return []int{after}
+}
+
+// selectHunks returns the diff with the wanted hunks only, failing when some
+// of them are not in the diff.
+func selectHunks(file string, diff unidiff.Diff, hunks WantedHunks) unidiff.Diff {
+	var missing []int
+	for n := range hunks {
+		if n > len(diff.Hunks) {
+			missing = append(missing, n)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Ints(missing)
+		numbers := make([]string, len(missing))
+		for i, n := range missing {
+			numbers[i] = strconv.Itoa(n)
+		}
+		wanted := strings.Join(numbers, ",")
+		switch len(diff.Hunks) {
+		case 0:
+			bail("error: -H %s: %s has no hunks", wanted, file)
+		case 1:
+			bail("error: -H %s: %s has hunk 1 only", wanted, file)
+		default:
+			bail("error: -H %s: %s has hunks 1-%d only", wanted, file, len(diff.Hunks))
+		}
+	}
+	selected := unidiff.Diff{OldMode: diff.OldMode, NewMode: diff.NewMode}
+	for i, hunk := range diff.Hunks {
+		if !hunks[i+1] {
+			continue
+		}
+		selected.Added += hunk.NumAdded
+		selected.Removed += hunk.NumRemoved
+		selected.Hunks = append(selected.Hunks, hunk)
+	}
+	return selected
+}
+
+func analyzeFile(file string, hunks WantedHunks, spec DiffSpec) *FileReport {
+	project := projectOf(file)
+	if isSubmodule(file) {
+		return &FileReport{File: file, Project: project, Submodule: submoduleChange(file, spec)}
+	}
+	// new files have no lines to blame
+	isNew := !inRevision(spec.BlameRev, file)
+	untracked := isNew && spec.worktree() && !isTracked(file)
+	if untracked {
+		// git diff --no-index exits with 1 for a missing file as for a new one
+		if _, err := os.Lstat(file); os.IsNotExist(err) {
+			bail("error: %s does not exist", file)
+		} else if err != nil {
+			bail("error: %v", err)
+		}
+		if !optUntracked {
+			bail("error: %s is not tracked, use -untracked to check it", file)
+		}
+	}
+	commitsAffected := map[string]MergeBaseTags{}
+
+	linesForCommit := map[string][]int{}
+	sourceLines := map[string][]int{}
+	sourcePaths := map[string]string{}
+	gitDiffArgs := diffArgs(fmt.Sprintf("-U%d", optContext))
+	if untracked {
+		gitDiffArgs = append(gitDiffArgs, "--no-index", "--", os.DevNull, file)
+	} else {
+		gitDiffArgs = append(gitDiffArgs, spec.Revs...)
+		gitDiffArgs = append(gitDiffArgs, "--", file)
+	}
+
+	diff := gitDiff(file, gitDiffArgs, untracked)
+	if hunks != nil {
+		diff = selectHunks(file, diff, hunks)
+	}
+
+	var symlink *SymlinkChange
+	if diff.OldMode == symlinkMode || diff.NewMode == symlinkMode {
+		symlink = symlinkChange(file, spec, diff)
+	}
+	if len(diff.Hunks) == 0 && !diff.ModeChanged() && symlink == nil && !untracked && unchanged(file, spec.Revs) {
+		return &FileReport{File: file, Project: project, Diff: diff,
+			Unchanged: true, UnchangedHint: changesElsewhere(file, spec)}
+	}
+	blame := Blame{{}}
+	if !isNew && symlink == nil {
+		blame = getBlame(file, spec.BlameRev, spec.IgnoreRevs)
+	}
+
+	ignored := map[string]bool{}
+	for _, sha1 := range spec.IgnoreRevs {
+		ignored[sha1] = true
+	}
+	affect := func(blame Blame, lnum int) {
+		if lnum <= 0 || lnum >= len(blame) {
+			slog.Debug("line out of the file", "file", file, "line", lnum, "lines", len(blame)-1)
+			return
+		}
+		commits := []string{blame.sha1(lnum)}
+		if optMerges != "" && commits[0] != "" {
+			commits = throughMerges(file, blame[lnum], spec.IgnoreRevs, 0)
+		}
+		for _, sha1 := range commits {
+			if len(sha1) == 0 || ignored[sha1] {
+				continue
+			}
+			if l := linesForCommit[sha1]; len(l) > 0 && l[len(l)-1] == lnum {
+				// a line next to two removed ones with -neighbor
+				continue
+			}
+			commitsAffected[sha1] = nil
+			linesForCommit[sha1] = append(linesForCommit[sha1], lnum)
+			if optLinks && sha1 == blame.sha1(lnum) {
+				// not for the commits found through merges
+				sourceLines[sha1] = append(sourceLines[sha1], blame[lnum].Source)
+				sourcePaths[sha1] = blame[lnum].Path
+			}
+		}
+	}
+	if symlink != nil && symlink.Commit != "" && !ignored[symlink.Commit] {
+		// the target is the single line of a symbolic link
+		commitsAffected[symlink.Commit] = nil
+		linesForCommit[symlink.Commit] = []int{1}
+	}
+	var moves *moves
+	moved := map[string][]int{}
+	if optDetectMoves {
+		moves = detectMoves(diff)
+	}
+	for _, hunk := range diff.Hunks {
+		var removedLines unidiff.Lines
+		if moves != nil {
+			removedLines = hunk.RemovedLines()
+		}
+		for i, parent := range hunk.Parents {
+			blame := blame
+			if len(hunk.Parents) > 1 {
+				// combined diff of a conflicted file
+				if i >= len(spec.ParentRevs) {
+					break
+				}
+				blame = getBlame(file, spec.ParentRevs[i], spec.IgnoreRevs)
+			}
+			for j, lnum := range parent.Removed {
+				if j < len(removedLines) && moves.moved(removedLines[j]) {
+					if lnum < len(blame) && blame.sha1(lnum) != "" {
+						moved[blame.sha1(lnum)] = append(moved[blame.sha1(lnum)], lnum)
+					}
+					continue
+				}
+				if optNeighbor != "" {
+					for _, n := range neighborLines(blame, lnum) {
+						affect(blame, n)
+					}
+					continue
+				}
+				affect(blame, offsetLine(file, blame, lnum))
+			}
+			if hunk.NumRemoved == 0 && moves.movedHere(hunk.AddedLines()) {
+				continue
+			}
+			for _, lnum := range parent.Inserted {
+				// no lines removed, blame the lines around the new ones
+				for _, n := range insertionLines(blame, lnum) {
+					affect(blame, n)
+				}
+			}
+		}
+	}
+	var commits []string
+	for sha1 := range commitsAffected {
+		commits = append(commits, sha1)
+	}
+	sort.Strings(commits)
+
+	report := &FileReport{
+		File:        file,
+		Project:     project,
+		New:         isNew,
+		Symlink:     symlink,
+		Diff:        diff,
+		Commits:     commitsAffected,
+		Issues:      map[string][]string{},
+		Lines:       linesForCommit,
+		Sources:     sourceLines,
+		SourcePaths: sourcePaths,
+		Moved:       moved,
+	}
+	if optAuthor || optByAuthor {
+		report.Authors = map[string]string{}
+	}
+	if optFirstRelease {
+		report.FirstRelease = map[string]string{}
+	}
+	if optDistance {
+		report.Distance = map[string]Distance{}
+	}
+	if optReleaseTags != "" {
+		report.ReleaseTags = map[string]ReleaseTags{}
+	}
+	if optRelated {
+		report.Related = map[string][]RelatedCommit{}
+	}
+
+	// The lookups are independent git queries, run them concurrently
+	var mu sync.Mutex
+	set := func(f func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		f()
+	}
+	var g group
+	g.Go(func() error {
+		branches := getAffectedBranches(commits, project.Branches)
+		set(func() { report.Branches = branches })
+		return nil
+	})
+	if report.Distance != nil {
+		g.Go(func() error {
+			distances := distancesFromHead(commits)
+			set(func() { report.Distance = distances })
+			return nil
+		})
+	}
+	for _, sha1 := range commits {
+		sha1 := sha1
+		g.Go(func() error {
+			tags := findMergeBaseTags(sha1, project.Tags)
+			set(func() { commitsAffected[sha1] = tags })
+			return nil
+		})
+		g.Go(func() error {
+			issues := getCommitIssues(sha1)
+			set(func() { report.Issues[sha1] = issues })
+			return nil
+		})
+		if report.Authors != nil {
+			g.Go(func() error {
+				author := getCommitAuthor(sha1)
+				set(func() { report.Authors[sha1] = author })
+				return nil
+			})
+		}
+		if report.FirstRelease != nil {
+			g.Go(func() error {
+				release := getFirstRelease(sha1, project.Tags)
+				set(func() { report.FirstRelease[sha1] = release })
+				return nil
+			})
+		}
+		if report.ReleaseTags != nil {
+			g.Go(func() error {
+				tags := findReleaseTags(sha1, optReleaseTags)
+				set(func() { report.ReleaseTags[sha1] = tags })
+				return nil
+			})
+		}
+		if report.Related != nil {
+			g.Go(func() error {
+				// the cached commits are shared, the tags depend on the project
+				related := append([]RelatedCommit(nil), relatedCommits(sha1)...)
+				for i := range related {
+					related[i].Tags = findMergeBaseTags(related[i].Commit, project.Tags)
+				}
+				set(func() { report.Related[sha1] = related })
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		bail("error: %v", err)
+	}
+	report.Unmerged = unmergedCommits(commits, report.Branches)
+
+	tagsSeen := map[string]int{}
+	for _, tags := range commitsAffected {
+		for _, tag := range tags {
+			tagsSeen[tag]++
+		}
+	}
+	for tag, count := range tagsSeen {
+		if count == len(commits) {
+			report.CommonTags = append(report.CommonTags, tag)
+		}
+	}
+	sort.Sort(report.CommonTags)
+
+	if report.ReleaseTags != nil && len(commits) > 0 {
+		seen := map[string]int{}
+		for _, tags := range report.ReleaseTags {
+			for _, tag := range tags {
+				seen[tag]++
+			}
+		}
+		// keep the version order of the tags
+		for _, tag := range report.ReleaseTags[commits[0]] {
+			if seen[tag] == len(commits) {
+				report.CommonReleaseTags = append(report.CommonReleaseTags, tag)
+			}
+		}
+	}
+	if optAddedVs != "" {
+		report.AddedOnRef = linesOnRef(file, optAddedVs, diff)
+	}
+	if optReverts {
+		report.Reverts = findReverts(file, diff, commits)
+	}
+	if optShowHunk && !isNew && symlink == nil {
+		addFuncnames(file, spec.BlameRev, diff.Hunks)
+	}
+	if optHistory > 0 && !isNew && symlink == nil {
+		report.History = lineHistory(file, spec.BlameRev, diff, len(blame)-1, optHistory)
+	}
+	if optBlameLines > 0 && !isNew && symlink == nil {
+		report.BlameContext = blameContext(file, spec.BlameRev, diff, blame, optBlameLines)
+	}
+	if optOwners {
+		report.Owners = codeOwners.owners(repoPath(file))
+		var paths []string
+		for _, sha1 := range commits {
+			paths = append(paths, getCommitPaths(sha1)...)
+		}
+		report.CommitOwners = codeOwners.ownersOf(paths)
+	}
+	return report
+}
+
+func showReport(r *FileReport) {
+	fmt.Printf("%s\n", r.File)
+	if r.Project != nil && r.Project.Dir != "" {
+		fmt.Printf("    Project: %s\n", r.Project.Dir)
+	}
+	if r.Submodule != nil {
+		showSubmodule(r.Submodule)
+		return
+	}
+	if r.Unchanged {
+		fmt.Printf("    No changes")
+		if r.UnchangedHint != "" {
+			fmt.Printf(" (%s)", r.UnchangedHint)
+		}
+		fmt.Println()
+		return
+	}
+	fmt.Printf("    Lines: %d removed, %d added\n", r.Diff.Removed, r.Diff.Added)
+	if optShowHunk {
+		showHunks(r.File, r.Diff.Hunks)
+	}
+	if r.Diff.ModeChanged() {
+		fmt.Printf("    Mode: %s -> %s\n", r.Diff.OldMode, r.Diff.NewMode)
+		if len(r.Diff.Hunks) == 0 {
+			fmt.Printf("    Permission change only, no line attribution\n")
+			return
+		}
+	}
+	if r.Symlink != nil {
+		showSymlink(r.Symlink)
+	}
+	showLinesOnRef(r.AddedOnRef, optAddedVs)
+	showReverts(r.Reverts)
+	showMoved(r.Moved)
+	if r.New {
+		fmt.Printf("    New file, no commits affected\n")
+		return
+	}
+
+	if len(r.CommonTags) > 0 {
+		// We have a common commit for all the affected commits
+		fmt.Printf("    Commits affected:\n")
+		// TODO when showing affected commits, sort them by their line numbers
+		for sha1, _ := range r.Commits {
+			showCommit(r, sha1)
+			if optShowLine {
+				showLines(r.Lines[sha1])
+			}
+		}
+		fmt.Printf("    Common tag:\n")
+		fmt.Printf("\t%s\n", r.CommonTags)
+		showTagCommits(r.CommonTags, "\t\t")
+		if optReleaseTags != "" {
+			fmt.Printf("    Common release tag:\n")
+			fmt.Printf("\t%s\n", r.CommonReleaseTags)
+		}
+	} else {
+		tagsSeen := map[string]int{}
+		for _, tags := range r.Commits {
+			for _, tag := range tags {
+				tagsSeen[tag]++
+			}
+		}
+
+		// print relevant tags for this sha1
+		fmt.Printf("    No common tags found for all the affected commits.\n")
+		for sha1, tags := range r.Commits {
+			showCommit(r, sha1)
+			fmt.Printf("\t\t")
+			tagsToShow := &bytes.Buffer{}
+			for _, tag := range tags.shown() {
+				if tagsSeen[tag] > 1 {
+					fmt.Fprintf(tagsToShow, "%s ", tag)
+				}
+			}
+			if tagsToShow.Len() > 0 {
+				fmt.Printf("%s\n", tagsToShow)
+			}
+			if optReleaseTags != "" {
+				fmt.Printf("\t\treleases: %s\n", r.ReleaseTags[sha1])
+			}
+			showLines(r.Lines[sha1])
+		}
+		showTagCover(r.Commits, "    ")
+	}
+	showBackportBranches(r.Branches, "    ")
+	if len(r.Commits) > 0 {
+		showSuggestion(r.CommonTags, r.Project.Branches, "    ")
+	}
+	if optPropagation {
+		showPropagation(r.Branches, r.Project.Branches, "    ")
+	}
+	showVerdict(r)
+	showUnmerged(r)
+	showHistory(r.History)
+	showBlameContext(r.BlameContext)
+
+	if optByAuthor {
+		showAuthors(r)
+	}
+
+	if optOwners {
+		fmt.Printf("    Owners: %s\n", strings.Join(r.Owners, " "))
+		fmt.Printf("    Owners of the affected commits: %s\n", strings.Join(r.CommitOwners, " "))
+	}
+}
+
+// showRecord prints the -z record of the report.
+func showRecord(r *FileReport) {
+	fmt.Printf("%d\t%d\t%s\t%s\t%s\x00", r.Diff.Removed, r.Diff.Added,
+		strings.Join(r.sortedCommits(), " "), strings.Join(r.CommonTags, " "), r.File)
+}
+
+// checkTarget prints whether all the affected commits of the report are
+// contained in the target branch.
+func checkTarget(r *FileReport, target string) bool {
+	var missing []string
+	for _, sha1 := range r.sortedCommits() {
+		if !isAncestor(sha1, target) {
+			missing = append(missing, shortSha1(sha1))
+		}
+	}
+	if optNulData {
+		return len(missing) == 0
+	}
+	if len(missing) > 0 {
+		fmt.Printf("    In %s: no (missing %s)\n", target, strings.Join(missing, " "))
+		return false
+	}
+	fmt.Printf("    In %s: yes\n", target)
+	return true
+}
+
+func showCommit(r *FileReport, sha1 string) {
+	fmt.Printf("\t%s", sha1)
+	if optShowDate {
+		showCommitDate(sha1)
+	}
+	if optAuthor {
+		fmt.Printf(" %s", r.Authors[sha1])
+	}
+	fmt.Printf(" (%s)", limitedBranches(r.Branches[sha1]))
+	if issues := r.Issues[sha1]; len(issues) > 0 {
+		fmt.Printf(" [%s]", strings.Join(issues, ", "))
+	}
+	if optFirstRelease {
+		fmt.Printf(" first release: %s", r.FirstRelease[sha1])
+	}
+	if optDistance {
+		fmt.Printf(" (%s)", r.Distance[sha1])
+	}
+	if optCommitURL != "" {
+		fmt.Printf(" %s", commitURL(sha1))
+	}
+	fmt.Println()
+	showRelated(r.Related[sha1])
+	if optLinks {
+		showLinks(r, sha1)
+	}
+}
+
+func getCommitDate(ref string) time.Time {
+	return dateCache.get(ref, func() time.Time {
+		l := linesFrom("git", "show", "--no-patch", "--format=%at", ref)
+		date := string(l[0])
+		n, err := strconv.Atoi(date)
+		if err != nil {
+			bail("error: cannot parse the date %s of %s: %v", date, ref, err)
+		}
+		return time.Unix(int64(n), 0)
+	})
+}
+
+// getCommitAuthor returns the author's name and email, as mapped by the
+// repository's .mailmap.
+func getCommitAuthor(ref string) string {
+	return authorCache.get(ref, func() string {
+		return string(linesFrom("git", "show", "--no-patch", "--format=%aN <%aE>", ref)[0])
+	})
+}
+
+// getFirstRelease returns the first tag, other than the merge base tags
+// matching mbPattern and matching -release-tags if given, that contains the
+// commit.
+func getFirstRelease(sha1, mbPattern string) string {
+	return firstReleaseCache.get(sha1+" "+mbPattern, func() string {
+		args := []string{"describe", "--contains", "--exclude", mbPattern}
+		if optReleaseTags != "" {
+			args = append(args, "--match", optReleaseTags)
+		}
+		out, err := gitOutput(append(args, sha1)...)
+		if err != nil {
+			// git describe fails when no tag contains the commit
+			return "none"
+		}
+		name := strings.TrimSpace(string(out))
+		if i := strings.IndexAny(name, "~^"); i >= 0 {
+			name = name[:i]
+		}
+		return name
+	})
+}
+
+func getCommitSubject(ref string) string {
+	return subjectCache.get(ref, func() string {
+		return string(linesFrom("git", "show", "--no-patch", "--format=%s", ref)[0])
+	})
+}
+
+// showAuthors prints the affected commits grouped by author, the authors
+// with the most changed lines first.
+func showAuthors(r *FileReport) {
+	lines := map[string]int{}
+	commits := map[string][]string{}
+	var authors []string
+	for _, sha1 := range r.sortedCommits() {
+		author := r.Authors[sha1]
+		if _, ok := commits[author]; !ok {
+			authors = append(authors, author)
+		}
+		lines[author] += len(r.Lines[sha1])
+		commits[author] = append(commits[author], sha1)
+	}
+	sort.SliceStable(authors, func(i, j int) bool {
+		return lines[authors[i]] > lines[authors[j]]
+	})
+
+	fmt.Printf("    Authors:\n")
+	for _, author := range authors {
+		fmt.Printf("\t%s: %d lines in %d commits\n", author, lines[author], len(commits[author]))
+		for _, sha1 := range commits[author] {
+			fmt.Printf("\t\t%s lines: %s\n", shortSha1(sha1), joinInts(r.Lines[sha1]))
+		}
+	}
+}
+
+func showLines(lnums []int) {
+	lines := &bytes.Buffer{}
+	for _, lnum := range lnums {
+		fmt.Fprintf(lines, "%d ", lnum)
+	}
+	if lines.Len() > 0 {
+		fmt.Printf("\tlines: %s\n", lines)
+	}
+}
+
+// detectDefaultBranch returns the branch the remote's HEAD points to,
+// falling back to init.defaultBranch and then develop, "" when none of them
+// exists.
+func detectDefaultBranch(remote string) string {
+	var candidates []string
+	out, err := gitOutput("symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(out)))
+	}
+	out, err = gitOutput("config", "init.defaultBranch")
+	if name := strings.TrimSpace(string(out)); err == nil && name != "" {
+		candidates = append(candidates, remote+"/"+name)
+	}
+	candidates = append(candidates, remote+"/develop")
+	for _, b := range candidates {
+		if _, err := gitOutput("rev-parse", "-q", "--verify", "refs/remotes/"+b+"^{commit}"); err == nil {
+			return b
+		}
+	}
+	return ""
+}
+
+type branchRef struct {
+	name string
+	sha1 string
+}
+
+var checkedBranchesCache cache[[]branchRef]
+
+// getCheckedBranches lists the branches of the remotes matching pattern,
+// the default branches and the local branches matching -local-branches, in
+// refname order.
+func getCheckedBranches(pattern string) []branchRef {
+	return checkedBranchesCache.get(pattern, func() []branchRef {
+		var checkedBranches []branchRef
+		args := []string{"for-each-ref", "--format=%(objectname) %(refname:short)"}
+		for _, remote := range remotes {
+			args = append(args, "refs/remotes/"+remote+"/"+pattern)
+		}
+		for _, b := range defaultBranches {
+			args = append(args, "refs/remotes/"+b)
+		}
+		if optLocalBranches != "" {
+			args = append(args, "refs/heads/"+optLocalBranches)
+		}
+		for _, line := range linesFrom("git", args...) {
+			fields := strings.SplitN(string(line), " ", 2)
+			if len(fields) == 2 {
+				checkedBranches = append(checkedBranches, branchRef{name: fields[1], sha1: fields[0]})
+			}
+		}
+		return checkedBranches
+	})
+}
+
+// getAffectedBranches returns the checked branches, with the release
+// branches matching pattern, containing each of the commits. The
+// containment of every commit and branch pair is checked in parallel.
+func getAffectedBranches(allCommits []string, pattern string) map[string][]string {
+	result := map[string][]string{}
+	var commits []string
+	for _, sha1 := range allCommits {
+		if b, ok := branchesCache.cached(sha1 + " " + pattern); ok {
+			result[sha1] = b
+		} else {
+			commits = append(commits, sha1)
+		}
+	}
+
+	branches := getCheckedBranches(pattern)
+	contained := make([][]bool, len(commits))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var g group
+	for i, sha1 := range commits {
+		contained[i] = make([]bool, len(branches))
+		for j, branch := range branches {
+			i, j, sha1, branch := i, j, sha1, branch.sha1
+			sem <- struct{}{}
+			g.Go(func() error {
+				defer func() { <-sem }()
+				contained[i][j] = isAncestor(sha1, branch)
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		bail("%v", err)
+	}
+
+	for i, sha1 := range commits {
+		result[sha1] = []string{}
+		for j, branch := range branches {
+			if contained[i][j] {
+				result[sha1] = append(result[sha1], branch.name)
+			}
+		}
+		branchesCache.set(sha1+" "+pattern, result[sha1])
+	}
+	return result
+}
+
+// findReleaseTags returns the tags matching pattern that contain the commit,
+// in version order.
+func findReleaseTags(sha1, pattern string) ReleaseTags {
+	return releaseTagsCache.get(sha1+" "+pattern, func() ReleaseTags {
+		if tags, ok := commitGraphTags(sha1, pattern); ok {
+			return tags
+		}
+		var tags ReleaseTags
+		for _, line := range linesFrom("git", "tag", "--contains", sha1, "--sort=v:refname", "-l", pattern) {
+			if len(line) > 0 {
+				tags = append(tags, string(line))
+			}
+		}
+		return tags
+	})
+}
+
+// findMergeBaseTags returns the merge base tags matching pattern containing
+// the commit, oldest first.
+func findMergeBaseTags(sha1, pattern string) MergeBaseTags {
+	return mergeBaseTagsCache.get(sha1+" "+pattern, func() MergeBaseTags {
+		var tags MergeBaseTags
+		if found, ok := commitGraphTags(sha1, pattern); ok {
+			tags = found
+		} else {
+			for _, line := range linesFrom("git", "tag", "--contains", sha1, "-l", pattern) {
+				if len(line) > 0 {
+					tags = append(tags, string(line))
+				}
+			}
+		}
+		sort.Sort(tags)
+		return tags
+	})
+}
+
+// commitGraphTags returns the tags matching pattern that contain the
+// commit using the commit-graph, if available.
+func commitGraphTags(sha1, pattern string) ([]string, bool) {
+	if commitGraph == nil {
+		return nil, false
+	}
+	return commitGraph.tagsContaining(sha1, pattern)
+}
+
+// Blame is the commit each line of a file comes from, for line numbers
+// from 1.
+type Blame []BlamedLine
+
+// BlamedLine is the commit a line comes from, and the number of the line
+// in the file of that commit and the path of that file, which differs from
+// the current one when the file was renamed since.
+type BlamedLine struct {
+	Commit string
+	Source int
+	Path   string
+}
+
+// getBlame blames the file in the revision. The incremental output of git
+// blame is used as it does not show the content of the lines, which can
+// have any encoding and line ending.
+func getBlame(file, rev string, ignoreRevs []string) Blame {
+	return blameCache.get(rev+":"+file, func() Blame {
+		args := []string{"blame", "--incremental", "--root", rev}
+		if len(ignoreRevs) > 0 && canIgnoreRevs() {
+			for _, sha1 := range ignoreRevs {
+				args = append(args, "--ignore-rev", sha1)
+			}
+		}
+		if len(optExcludeAuthors) > 0 && canIgnoreRevs() {
+			logArgs := []string{"log", "--format=%H"}
+			for _, pattern := range optExcludeAuthors {
+				logArgs = append(logArgs, "--author="+pattern)
+			}
+			logArgs = append(logArgs, rev, "--", file)
+			for _, sha1 := range linesFrom("git", logArgs...) {
+				if len(sha1) > 0 {
+					args = append(args, "--ignore-rev", string(sha1))
+				}
+			}
+		}
+		args = append(args, file)
+
+		blame := Blame{{}}
+		var start, count int
+		for _, line := range linesFrom("git", args...) {
+			// <sha1> <source line> <result line> <number of lines>,
+			// followed by the headers of the commit, ending with the
+			// filename in it
+			if name := strings.TrimPrefix(string(line), "filename "); len(name) < len(line) {
+				if unquoted, err := strconv.Unquote(name); err == nil {
+					name = unquoted
+				}
+				for i := 0; i < count; i++ {
+					blame[start+i].Path = name
+				}
+				count = 0
+				continue
+			}
+			fields := strings.Fields(string(line))
+			if len(fields) != 4 || !isObjectName(fields[0]) {
+				continue
+			}
+			source, err0 := strconv.Atoi(fields[1])
+			lnum, err1 := strconv.Atoi(fields[2])
+			n, err2 := strconv.Atoi(fields[3])
+			if err0 != nil || err1 != nil || err2 != nil || lnum < 1 || n < 0 {
+				continue
+			}
+			start, count = lnum, n
+			for len(blame) < start+count {
+				blame = append(blame, BlamedLine{})
+			}
+			for i := 0; i < count; i++ {
+				blame[start+i] = BlamedLine{Commit: fields[0], Source: source + i}
+			}
+		}
+		return blame
+	})
+}
+
+func (b Blame) sha1(lnum int) string {
+	return b[lnum].Commit
+}
+
+// isObjectName tells whether s is a full SHA-1 or SHA-256 object name.
+func isObjectName(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// addNote attaches the plain text report as a git note on the commit,
+// replacing any previous note made by git check-diff.
+func addNote(commit string, reports []*FileReport, commonTags MergeBaseTags) {
+	run("git", "notes", "--ref", "check-diff", "add", "-f", "-m", reportText(reports, commonTags), commit)
+}
+
+// isAncestor returns true if commit is an ancestor of, or the same as, ref.
+func isAncestor(commit, ref string) bool {
+	if commitGraph != nil {
+		if contained, ok := commitGraph.isAncestor(commit, ref); ok {
+			return contained
+		}
+	}
+	_, err := gitOutput("merge-base", "--is-ancestor", commit, ref)
+	if err == nil {
+		return true
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false
+	}
+	bail("git merge-base --is-ancestor %s %s: %v", commit, ref, err)
+	return false
+}
+
+func linesFrom(command string, arg ...string) [][]byte {
+	return bytes.Split(run(command, arg...), []byte{'\n'})
+}
+
+// procs limits the number of concurrent subprocesses, see -max-procs.
+var procs chan struct{}
+
+func acquireProc() {
+	if procs != nil {
+		procs <- struct{}{}
+	}
+}
+
+func releaseProc() {
+	if procs != nil {
+		<-procs
+	}
+}
+
+// gitOutput runs git and returns its output, leaving the error handling
+// to the caller.
+func gitOutput(arg ...string) ([]byte, error) {
+	return output("git", arg...)
+}
+
+// output runs the command and returns its output, retrying while it fails
+// on a git lock file, see -lock-timeout.
+func output(name string, arg ...string) ([]byte, error) {
+	retry := newLockRetry()
+	for {
+		acquireProc()
+		cmd, done := command(name, arg...)
+		out, err := cmd.Output()
+		releaseProc()
+		err = done(err)
+		if e, ok := err.(*exec.ExitError); ok && retry.again(e.Stderr) {
+			continue
+		}
+		return out, err
+	}
+}
+
+// setGitEnv sets the environment the git subprocesses inherit so that
+// their output parses the same for every user: untranslated messages and
+// unquoted non-ASCII paths.
+func setGitEnv() {
+	os.Setenv("LC_ALL", "C")
+	os.Setenv("LANG", "C")
+	os.Unsetenv("LANGUAGE")
+	checkGitVersion()
+	// git config -c core.quotepath=false for all the git commands
+	if !installedGit.atLeast(configEnvGitVersion) {
+		params := strings.TrimSpace(os.Getenv("GIT_CONFIG_PARAMETERS") + " 'core.quotepath'='false'")
+		os.Setenv("GIT_CONFIG_PARAMETERS", params)
+		return
+	}
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", n), "core.quotepath")
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", n), "false")
+	os.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(n+1))
+}
+
+// diffArgs returns the git arguments for running git diff with args in a
+// canonical form, whatever the diff.* configuration or external diff
+// drivers of the user.
+func diffArgs(args ...string) []string {
+	return append([]string{"-c", "diff.noprefix=false", "-c", "diff.mnemonicprefix=false",
+		"diff", "--no-ext-diff", "--no-textconv", "--no-color"}, args...)
 }
 
 func run(name string, arg ...string) []byte {
-	buf, err := exec.Command(name, arg...).Output()
+	buf, err := output(name, arg...)
 	if err != nil {
 		bail("%v", err)
 	}
@@ -381,6 +2325,14 @@ func run(name string, arg ...string) []byte {
 }
 
 func bail(format string, args ...interface{}) {
+	stopIfInterrupted()
+	if atomic.LoadInt32(&softFailures) == 1 {
+		panic(failure(fmt.Sprintf(format, args...)))
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	exit(1)
+}
+
+func warn(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
-	os.Exit(1)
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// showMatrix prints a table with the files and their affected commits as
+// rows and the checked branches as columns, including the ones containing
+// none of the commits. A file is marked as contained in a branch when all of
+// its affected commits are.
+func showMatrix(reports []*FileReport) {
+	var columns []string
+	seen := map[string]bool{}
+	for _, r := range reports {
+		if r.Project == nil {
+			continue
+		}
+		for _, b := range getCheckedBranches(r.Project.Branches) {
+			if !seen[b.name] {
+				seen[b.name] = true
+				columns = append(columns, b.name)
+			}
+		}
+	}
+	sortBranches(columns)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\n", strings.Join(columns, "\t"))
+	for _, r := range reports {
+		var commits []string
+		for sha1 := range r.Commits {
+			commits = append(commits, sha1)
+		}
+		sort.Strings(commits)
+
+		fmt.Fprintf(w, "%s", r.File)
+		for _, b := range columns {
+			contained := len(commits) > 0
+			for _, sha1 := range commits {
+				if !hasBranch(r.Branches[sha1], b) {
+					contained = false
+					break
+				}
+			}
+			fmt.Fprintf(w, "\t%s", containmentMark(contained))
+		}
+		fmt.Fprintln(w)
+
+		for _, sha1 := range commits {
+			fmt.Fprintf(w, "  %s", shortSha1(sha1))
+			for _, b := range columns {
+				fmt.Fprintf(w, "\t%s", containmentMark(hasBranch(r.Branches[sha1], b)))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	w.Flush()
+}
+
+func containmentMark(contained bool) string {
+	if contained {
+		return "x"
+	}
+	return "-"
+}
+
+func hasBranch(branches []string, branch string) bool {
+	for _, b := range branches {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
+
+func shortSha1(sha1 string) string {
+	if len(sha1) > 12 {
+		return sha1[:12]
+	}
+	return sha1
+}
+
+// sortBranches sorts release branches by their version number, oldest
+// first, followed by the remaining branches in lexical order.
+func sortBranches(branches []string) {
+	sort.Slice(branches, func(i, j int) bool {
+		vi, iok := releaseNumber(branches[i])
+		vj, jok := releaseNumber(branches[j])
+		switch {
+		case iok && jok && vi != vj:
+			return vi < vj
+		case iok != jok:
+			return iok
+		}
+		return branches[i] < branches[j]
+	})
+}
+
+// releaseNumber returns N for a release branch named <remote>/release-N,
+// or any other name ending with -N or _N.
+func releaseNumber(branch string) (int, bool) {
+	i := strings.LastIndexAny(branch, "-_")
+	if i < 0 || i < strings.LastIndex(branch, "/") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(branch[i+1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
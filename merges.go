@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// maxMergeDepth bounds the nested merges looked through for a line.
+const maxMergeDepth = 16
+
+var parentsCache cache[[]string]
+
+// commitParents returns the parents of the commit, more than one for a
+// merge.
+func commitParents(sha1 string) []string {
+	return parentsCache.get(sha1, func() []string {
+		out := run("git", "show", "--no-patch", "--format=%P", sha1)
+		return strings.Fields(string(out))
+	})
+}
+
+// throughMerges returns the commits the line comes from when it is blamed
+// on a merge commit, with -merges: the line is blamed again in the first
+// parent or in all the parents of the merge, at the place the merge changed.
+// The merge stays the commit of the line when no parent has it, or when the
+// merges nest too deep.
+func throughMerges(file string, line BlamedLine, ignoreRevs []string, depth int) []string {
+	parents := commitParents(line.Commit)
+	if len(parents) < 2 || depth == maxMergeDepth {
+		return []string{line.Commit}
+	}
+	if optMerges == "first-parent" {
+		parents = parents[:1]
+	}
+	var commits []string
+	for _, parent := range parents {
+		if !inRevision(parent, file) {
+			continue
+		}
+		lnum := parentLine(file, parent, line.Commit, line.Source)
+		blame := getBlame(file, parent, ignoreRevs)
+		if lnum < 1 || lnum >= len(blame) || blame.sha1(lnum) == "" {
+			continue
+		}
+		commits = append(commits, throughMerges(file, blame[lnum], ignoreRevs, depth+1)...)
+	}
+	if len(commits) == 0 {
+		slog.Debug("line not found in the parents of the merge", "file", file, "merge", line.Commit, "line", line.Source)
+		return []string{line.Commit}
+	}
+	slog.Debug("line blamed through a merge", "file", file, "merge", line.Commit, "commits", commits)
+	return commits
+}
+
+// parentLine returns the line of the file in the parent that corresponds
+// to the line lnum of the merge: the line at the same place in the lines
+// the merge replaced, or the line the merge added lines after.
+func parentLine(file, parent, merge string, lnum int) int {
+	args := diffArgs("-U0", parent, merge, "--", file)
+	out, err := gitOutput(args...)
+	if err != nil {
+		return 0
+	}
+	diff, err := unidiff.Parse(bytes.NewReader(out), false)
+	if err != nil {
+		return 0
+	}
+	shift := 0
+	for _, hunk := range diff.Hunks {
+		added, removed := hunk.Added, hunk.Removed
+		if added.Count > 0 && lnum < added.Start || added.Count == 0 && lnum <= added.Start {
+			break
+		}
+		if lnum < added.Start+added.Count {
+			if removed.Count == 0 {
+				// the start is the line before an empty range
+				return removed.Start
+			}
+			offset := lnum - added.Start
+			if offset >= removed.Count {
+				offset = removed.Count - 1
+			}
+			return removed.Start + offset
+		}
+		shift += removed.Count - added.Count
+	}
+	return lnum + shift
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// moves tells the lines of a diff that are moved rather than changed: the
+// removed lines added back verbatim elsewhere in the diff. Moving old code
+// is less risky than changing it, with -detect-moves the commits of moved
+// lines are not affected commits.
+type moves struct {
+	removed map[string]int
+	added   map[string]int
+}
+
+func detectMoves(diff unidiff.Diff) *moves {
+	var removed, added unidiff.Lines
+	for _, hunk := range diff.Hunks {
+		if len(hunk.Parents) > 1 {
+			// not for the combined diffs of conflicts
+			return nil
+		}
+		removed = append(removed, hunk.RemovedLines()...)
+		added = append(added, hunk.AddedLines()...)
+	}
+	return &moves{removed: lineCounts(removed), added: lineCounts(added)}
+}
+
+// moved tells whether the removed line is added back, each added line
+// matching a single removed one.
+func (m *moves) moved(line []byte) bool {
+	if m == nil || m.added[string(line)] == 0 {
+		return false
+	}
+	m.added[string(line)]--
+	return true
+}
+
+// movedHere tells whether all the lines added are removed elsewhere, the
+// destination of a move.
+func (m *moves) movedHere(lines unidiff.Lines) bool {
+	if m == nil {
+		return false
+	}
+	n := 0
+	for _, text := range lines {
+		if m.removed[string(text)] == 0 {
+			if _, counted := m.added[string(text)]; counted {
+				return false
+			}
+			// a blank line or a brace
+			continue
+		}
+		n++
+	}
+	return n > 0
+}
+
+func showMoved(moved map[string][]int) {
+	if len(moved) == 0 {
+		return
+	}
+	var commits []string
+	for sha1 := range moved {
+		commits = append(commits, sha1)
+	}
+	sort.Strings(commits)
+	fmt.Printf("    Moved lines, not attributed:\n")
+	for _, sha1 := range commits {
+		fmt.Printf("\t%s %s\n", shortSha1(sha1), getCommitSubject(sha1))
+		showLines(moved[sha1])
+	}
+}
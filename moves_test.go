@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestMovesMoved(t *testing.T) {
+	m := &moves{added: map[string]int{"x := 1": 1, "y := 2": 2}}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"x := 1", true},
+		{"x := 1", false},
+		{"y := 2", true},
+		{"y := 2", true},
+		{"y := 2", false},
+		{"z := 3", false},
+	}
+	for i, tt := range tests {
+		if got := m.moved([]byte(tt.line)); got != tt.want {
+			t.Errorf("tests[%d]: moved(%q) = %v, want %v", i, tt.line, got, tt.want)
+		}
+	}
+	if (*moves)(nil).moved([]byte("x := 1")) {
+		t.Errorf("nil moves: moved = true, want false")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type notifyFile struct {
+	File       string              `json:"file"`
+	CommonTags []string            `json:"common_tags"`
+	Commits    []string            `json:"commits"`
+	Issues     map[string][]string `json:"issues,omitempty"`
+}
+
+// notifyPayload is accepted as is by Slack incoming webhooks, which only
+// look at Text, while other receivers get the structured fields.
+type notifyPayload struct {
+	Text       string       `json:"text"`
+	Files      []notifyFile `json:"files"`
+	CommonTags []string     `json:"common_tags"`
+}
+
+// notify POSTs a summary of the reports to the webhook at url.
+func notify(url string, reports []*FileReport, commonTags MergeBaseTags) error {
+	p := notifyPayload{CommonTags: []string(commonTags)}
+	text := &bytes.Buffer{}
+	fmt.Fprintf(text, "git-check-diff:")
+	for _, r := range reports {
+		f := notifyFile{
+			File:       r.File,
+			CommonTags: []string(r.CommonTags),
+			Issues:     map[string][]string{},
+		}
+		for sha1 := range r.Commits {
+			f.Commits = append(f.Commits, sha1)
+		}
+		sort.Strings(f.Commits)
+		for sha1, issues := range r.Issues {
+			if len(issues) > 0 {
+				f.Issues[sha1] = issues
+			}
+		}
+		p.Files = append(p.Files, f)
+
+		if len(r.CommonTags) > 0 {
+			fmt.Fprintf(text, "\n%s: %s", r.File, r.CommonTags[0])
+		} else {
+			var short []string
+			for _, sha1 := range f.Commits {
+				short = append(short, shortSha1(sha1))
+			}
+			fmt.Fprintf(text, "\n%s: no common tag (commits %s)", r.File, strings.Join(short, ", "))
+		}
+	}
+	if len(reports) > 1 {
+		if len(commonTags) > 0 {
+			fmt.Fprintf(text, "\ncommon tag: %s", commonTags[0])
+		} else {
+			fmt.Fprintf(text, "\nno common tag")
+		}
+	}
+	p.Text = text.String()
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
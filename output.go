@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// setOutput sends the standard output to the file of -o.
+func setOutput(name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		bail("error: -o: %v", err)
+	}
+	os.Stdout = f
+}
+
+// pipeOutput sends the standard output through the -pipe command, run by
+// the shell, whose output goes where the standard output went. It is
+// waited for on exit, failing when it fails.
+func pipeOutput(command string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		bail("error: -pipe: %v", err)
+	}
+	// not killed on interrupt, to show the partial results
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		bail("error: -pipe: %v", err)
+	}
+	r.Close()
+	os.Stdout = w
+	onExit(func() {
+		w.Close()
+		if err := cmd.Wait(); err != nil {
+			warn("error: -pipe %s: %v", command, err)
+			failedOnExit = true
+		}
+	})
+}
+
+// writeReport writes what show prints about the file to its own file in
+// the directory dir, at the path of the file in the repository with the
+// extension ext added.
+func writeReport(dir, file, ext string, show func()) error {
+	name := filepath.Join(dir, filepath.FromSlash(repoPath(file))+ext)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() {
+		os.Stdout = stdout
+		f.Close()
+	}()
+	show()
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+var (
+	atExit   []func()
+	atExitMu sync.Mutex
+	// set by the functions of atExit to exit with 1 instead of 0
+	failedOnExit bool
+)
+
+// exit runs the functions registered with onExit, which flush the
+// profiles and the output of -pipe, and exits.
+func exit(code int) {
+	atExitMu.Lock()
+	for i := len(atExit) - 1; i >= 0; i-- {
+		atExit[i]()
+	}
+	atExit = nil
+	if code == 0 && failedOnExit {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func onExit(f func()) {
+	atExitMu.Lock()
+	defer atExitMu.Unlock()
+	atExit = append(atExit, f)
+}
+
+// startProfiling starts the profiles requested with -cpuprofile,
+// -memprofile and -exec-trace. They are written when the program exits.
+func startProfiling() {
+	if optCPUProfile != "" {
+		f, err := os.Create(optCPUProfile)
+		if err != nil {
+			bail("error: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			bail("error: %v", err)
+		}
+		onExit(func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if optExecTrace != "" {
+		f, err := os.Create(optExecTrace)
+		if err != nil {
+			bail("error: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			bail("error: %v", err)
+		}
+		onExit(func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if optMemProfile != "" {
+		onExit(func() {
+			f, err := os.Create(optMemProfile)
+			if err != nil {
+				warn("error: %v", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				warn("error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// projectConfig is the name of the file configuring the release train of
+// the directory it is in, in the git config format:
+//
+//	[check-diff]
+//		tags = SVC_MERGE_BASE_*
+//		branches = svc-release-*
+//
+// The one at the top of the repository can also have profiles, selected
+// with -profile, setting the tags and branches of the repository and
+// defaults for the other flags:
+//
+//	[profile "lts"]
+//		tags = LTS_MERGE_BASE_*
+//		branches = lts-*
+//		max-affected = 3
+const projectConfig = ".git-check-diff"
+
+// Project is a part of the repository with its own release train: a
+// directory with a .git-check-diff file, or the whole repository.
+type Project struct {
+	// Directory relative to the top of the repository, "" for the root
+	Dir string
+	// Pattern of the merge base tags
+	Tags string
+	// Pattern of the release branches of the remotes
+	Branches string
+}
+
+// Name returns the directory of the project, / for the root.
+func (p *Project) Name() string {
+	if p.Dir == "" {
+		return "/"
+	}
+	return p.Dir
+}
+
+var (
+	// tags and branches of the -profile, "" when it does not set them
+	profileTags     string
+	profileBranches string
+
+	projectCache cache[*Project]
+	topLevel     string
+	topLevelOnce sync.Once
+)
+
+func getTopLevel() string {
+	topLevelOnce.Do(func() {
+		topLevel = strings.TrimSpace(string(run("git", "rev-parse", "--show-toplevel")))
+	})
+	return topLevel
+}
+
+// projectOf returns the project of the file: the one of the nearest
+// directory above it with a .git-check-diff file.
+func projectOf(file string) *Project {
+	return projectAt(path.Dir(repoPath(file)))
+}
+
+func projectAt(dir string) *Project {
+	if dir == "." || dir == "/" {
+		dir = ""
+	}
+	return projectCache.get(dir, func() *Project {
+		config := filepath.Join(getTopLevel(), filepath.FromSlash(dir), projectConfig)
+		p := &Project{Dir: dir, Tags: "MERGE_BASE_*", Branches: "release-*"}
+		if _, err := os.Stat(config); err != nil {
+			if dir != "" {
+				return projectAt(path.Dir(dir))
+			}
+		} else {
+			slog.Debug("project config", "file", config)
+			if out, err := gitOutput("config", "-f", config, "check-diff.tags"); err == nil {
+				p.Tags = strings.TrimSpace(string(out))
+			}
+			if out, err := gitOutput("config", "-f", config, "check-diff.branches"); err == nil {
+				p.Branches = strings.TrimSpace(string(out))
+			}
+		}
+		if dir == "" && profileTags != "" {
+			p.Tags = profileTags
+		}
+		if dir == "" && profileBranches != "" {
+			p.Branches = profileBranches
+		}
+		return p
+	})
+}
+
+// useProfile applies the profile of the .git-check-diff file at the top of
+// the repository: its tags and branches, and its other settings to the
+// flags not given on the command line or in the environment.
+func useProfile(name string) {
+	config := filepath.Join(getTopLevel(), projectConfig)
+	section := "profile." + name + "."
+	// --null: the key, a newline, the value and a NUL
+	out, err := gitOutput("config", "-f", config, "--null", "--get-regexp", "^"+regexp.QuoteMeta(section))
+	if err != nil {
+		bail("error: -profile %s: no such profile in %s", name, projectConfig)
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, entry := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		key, value := entry, "true"
+		if i := strings.IndexByte(entry, '\n'); i >= 0 {
+			key, value = entry[:i], entry[i+1:]
+		}
+		key = strings.TrimPrefix(key, section)
+		switch key {
+		case "tags":
+			profileTags = value
+			slog.Info("profile setting", "profile", name, "tags", value)
+			continue
+		case "branches":
+			profileBranches = value
+			slog.Info("profile setting", "profile", name, "branches", value)
+			continue
+		}
+		f := lookupFlag(key)
+		if f == nil {
+			bail("error: -profile %s: %s: no such flag", name, key)
+		}
+		if given[f.Name] || envFlags[f.Name] {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			bail("error: -profile %s: %s = %s: %v", name, key, value, err)
+		}
+		slog.Info("profile setting", "profile", name, "flag", f.Name, "value", value)
+	}
+}
+
+// lookupFlag returns the flag named name, ignoring the case as git config
+// does for the names of the settings.
+func lookupFlag(name string) *flag.Flag {
+	var found *flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		if strings.EqualFold(f.Name, name) {
+			found = f
+		}
+	})
+	return found
+}
+
+// groupByProject orders the files by project, in the order the projects
+// first appear, and returns the number of projects.
+func groupByProject(files []string) int {
+	order := map[string]int{}
+	projects := make([]*Project, len(files))
+	for i, file := range files {
+		projects[i] = projectOf(file)
+		if _, ok := order[projects[i].Dir]; !ok {
+			order[projects[i].Dir] = len(order)
+		}
+	}
+	sort.Stable(byProject{files, projects, order})
+	return len(order)
+}
+
+type byProject struct {
+	files    []string
+	projects []*Project
+	order    map[string]int
+}
+
+func (b byProject) Len() int { return len(b.files) }
+func (b byProject) Swap(i, j int) {
+	b.files[i], b.files[j] = b.files[j], b.files[i]
+	b.projects[i], b.projects[j] = b.projects[j], b.projects[i]
+}
+func (b byProject) Less(i, j int) bool {
+	return b.order[b.projects[i].Dir] < b.order[b.projects[j].Dir]
+}
+
+// reportsByProject splits the reports by project, keeping their order.
+func reportsByProject(reports []*FileReport) (projects []*Project, grouped map[string][]*FileReport) {
+	grouped = map[string][]*FileReport{}
+	for _, r := range reports {
+		if _, ok := grouped[r.Project.Dir]; !ok {
+			projects = append(projects, r.Project)
+		}
+		grouped[r.Project.Dir] = append(grouped[r.Project.Dir], r)
+	}
+	return projects, grouped
+}
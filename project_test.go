@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseProfilePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := "[profile \"ci\"]\n\tcontext = 5\n\tlimit = 7\n\tmax-procs = 2\n"
+	if err := os.WriteFile(filepath.Join(dir, projectConfig), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	topLevelOnce.Do(func() {})
+	savedTopLevel, savedCommandLine, savedEnvFlags := topLevel, flag.CommandLine, envFlags
+	t.Cleanup(func() {
+		topLevel, flag.CommandLine, envFlags = savedTopLevel, savedCommandLine, savedEnvFlags
+	})
+	topLevel = dir
+	flag.CommandLine = flag.NewFlagSet("git-check-diff", flag.ContinueOnError)
+	envFlags = map[string]bool{}
+
+	var context, limit, maxProcs int
+	flag.IntVar(&context, "context", 0, "")
+	flag.IntVar(&limit, "limit", 0, "")
+	flag.IntVar(&maxProcs, "max-procs", 0, "")
+	t.Setenv(envName("context"), "9")
+	t.Setenv(envName("max-procs"), "4")
+	setFlagsFromEnv()
+	if err := flag.CommandLine.Parse([]string{"-max-procs", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	useProfile("ci")
+
+	// the command line, then the environment, then the profile
+	if maxProcs != 1 || context != 9 || limit != 7 {
+		t.Errorf("max-procs, context, limit = %d, %d, %d, want 1, 9, 7", maxProcs, context, limit)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var forkDepthCache cache[int]
+
+// forkDepth returns the number of commits up to the point where the branch
+// forked from the default branch of its remote, the default branch itself
+// up to its tip: merged upward, a branch flows into the ones that forked
+// after it. It returns -1 when they have no common history or the branch
+// does not exist.
+func forkDepth(branch string) int {
+	return forkDepthCache.get(branch, func() int {
+		fork := branch
+		remote := branch[:strings.LastIndex(branch, "/")+1]
+		for _, b := range defaultBranches {
+			if remote != "" && strings.HasPrefix(b, remote) && b != branch && forkDepth(b) >= 0 {
+				out, err := gitOutput("merge-base", branch, b)
+				if err != nil {
+					return -1
+				}
+				fork = strings.TrimSpace(string(out))
+				break
+			}
+		}
+		out, err := gitOutput("rev-list", "--count", fork)
+		if err != nil {
+			return -1
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		if err != nil {
+			return -1
+		}
+		return n
+	})
+}
+
+// propagation returns the branches a fix landing on base reaches by being
+// merged upward, base first: the releases of the same remote that forked
+// after it, in the order depth gives them, and then its default branch.
+// The branches depth cannot place, such as a default branch that does not
+// exist, are left out.
+func propagation(base string, releases, defaults []string, depth func(string) int) []string {
+	remote := base[:strings.LastIndex(base, "/")+1]
+	var candidates []string
+	for _, b := range append(append([]string(nil), releases...), defaults...) {
+		if strings.HasPrefix(b, remote) && !strings.Contains(b[len(remote):], "/") {
+			candidates = append(candidates, b)
+		}
+	}
+	sortBranches(candidates)
+	var chain []string
+	depths := map[string]int{}
+	for _, b := range candidates {
+		if depths[b] = depth(b); depths[b] >= 0 || b == base {
+			chain = append(chain, b)
+		}
+	}
+	sort.SliceStable(chain, func(i, j int) bool { return depths[chain[i]] < depths[chain[j]] })
+	for i, b := range chain {
+		if b == base {
+			return chain[i:]
+		}
+	}
+	return []string{base}
+}
+
+// showPropagation shows how the fix spreads from the oldest branch to
+// backport it to: each newer branch diverges from the previous one, lacking
+// the fix, until that one is merged into it.
+func showPropagation(branches map[string][]string, pattern, indent string) {
+	backport := backportBranches(branches)
+	if len(backport) == 0 {
+		return
+	}
+	chain := propagation(backport[0], activeReleases(pattern), defaultBranches, forkDepth)
+	fmt.Printf("%sPropagation: %s\n", indent, strings.Join(chain, " -> "))
+	for i := 1; i < len(chain); i++ {
+		fmt.Printf("\t%s diverges from %s until it is merged\n", chain[i], chain[i-1])
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPropagation(t *testing.T) {
+	releases := []string{"origin/release-10", "origin/release-9", "origin/release-8", "upstream/release-9"}
+	defaults := []string{"origin/develop", "origin/main", "upstream/develop"}
+	tests := []struct {
+		base string
+		want []string
+	}{
+		{"origin/release-9", []string{"origin/release-9", "origin/release-10", "origin/develop"}},
+		{"upstream/release-9", []string{"upstream/release-9", "upstream/develop"}},
+		{"origin/develop", []string{"origin/develop"}},
+		{"release-7", []string{"release-7"}},
+	}
+	// forked in version order, the default branches last, origin/main
+	// missing
+	depth := func(b string) int {
+		if b == "origin/main" {
+			return -1
+		}
+		if n, ok := releaseNumber(b); ok {
+			return n
+		}
+		return 100
+	}
+	for _, tt := range tests {
+		if got := propagation(tt.base, releases, defaults, depth); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("propagation(%q) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
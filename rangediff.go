@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rangeCommits returns the non-merge commits of the revision range, oldest
+// first.
+func rangeCommits(revs ...string) []string {
+	var commits []string
+	args := append([]string{"rev-list", "--reverse", "--no-merges"}, revs...)
+	for _, line := range linesFrom("git", args...) {
+		if len(line) > 0 {
+			commits = append(commits, string(line))
+		}
+	}
+	return commits
+}
+
+// analyzeRange checks the changes of each commit of the revision range
+// against its parent. Lines added by commits of the range itself are looked
+// through, as for a patch series.
+func analyzeRange(revs ...string) (commits []string, reports []*FileReport) {
+	commits = rangeCommits(revs...)
+	for _, sha1 := range commits {
+		out, err := gitOutput("rev-parse", "-q", "--verify", sha1+"^")
+		if err != nil {
+			// root commit, nothing to blame
+			continue
+		}
+		parent := strings.TrimSpace(string(out))
+		spec := DiffSpec{Revs: []string{parent, sha1}, BlameRev: parent, IgnoreRevs: commits}
+		for result := range analyzeFiles(changedFiles(spec), nil, spec) {
+			reports = append(reports, result.report)
+		}
+	}
+	return commits, reports
+}
+
+// compareRanges checks two versions of a series, given as revision ranges,
+// and shows how the affected commits and their common tag changed. Commits
+// only the new version touches are flagged when they are not in the oldest
+// common tag of the old version, since the new version then no longer
+// backports as far.
+func compareRanges(oldRange, newRange string) {
+	oldCommits, oldReports := analyzeRange(oldRange)
+	newCommits, newReports := analyzeRange(newRange)
+	oldTags := commonTagsOf(oldReports)
+	newTags := commonTagsOf(newReports)
+
+	fmt.Printf("Old: %s (%d commits), common tag: %s\n", oldRange, len(oldCommits), tagsOrNone(oldTags))
+	fmt.Printf("New: %s (%d commits), common tag: %s\n", newRange, len(newCommits), tagsOrNone(newTags))
+
+	affected := func(reports []*FileReport) map[string]MergeBaseTags {
+		commits := map[string]MergeBaseTags{}
+		for _, r := range reports {
+			for sha1, tags := range r.Commits {
+				commits[sha1] = tags
+			}
+		}
+		return commits
+	}
+	oldAffected := affected(oldReports)
+	newAffected := affected(newReports)
+
+	var commits []string
+	dates := map[string]time.Time{}
+	for _, m := range []map[string]MergeBaseTags{oldAffected, newAffected} {
+		for sha1 := range m {
+			if _, ok := dates[sha1]; !ok {
+				commits = append(commits, sha1)
+				dates[sha1] = getCommitDate(sha1)
+			}
+		}
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		if !dates[commits[i]].Equal(dates[commits[j]]) {
+			return dates[commits[i]].Before(dates[commits[j]])
+		}
+		return commits[i] < commits[j]
+	})
+
+	fmt.Printf("Affected commits:\n")
+	risky := 0
+	for _, sha1 := range commits {
+		_, inOld := oldAffected[sha1]
+		tags, inNew := newAffected[sha1]
+		mark := "="
+		switch {
+		case !inNew:
+			mark = "-"
+		case !inOld:
+			mark = "+"
+		}
+		fmt.Printf("\t%s %s %s %s", mark, shortSha1(sha1),
+			getCommitDate(sha1).Format("2006-01-02"), getCommitSubject(sha1))
+		if mark == "+" && len(oldTags) > 0 && !tags.contains(oldTags[0]) {
+			fmt.Printf(" (not in %s)", oldTags[0])
+			risky++
+		}
+		fmt.Println()
+	}
+
+	if slices.Equal(oldTags, newTags) {
+		fmt.Printf("Common tag unchanged\n")
+	} else {
+		fmt.Printf("Common tag changed: %s -> %s\n", tagsOrNone(oldTags), tagsOrNone(newTags))
+	}
+	if risky > 0 {
+		fmt.Printf("The new version touches %d commit(s) not in %s\n", risky, oldTags[0])
+	}
+}
+
+func (tags MergeBaseTags) contains(tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func tagsOrNone(tags MergeBaseTags) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	return strings.TrimSpace(tags.String())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RelatedCommit is a commit of the family of an affected commit: one with
+// the same Gerrit Change-Id, such as a cherry-pick to another branch, or
+// one that a Fixes: trailer links it to.
+type RelatedCommit struct {
+	Commit string
+	// "same Change-Id", "fixes" for the commits the affected one fixes, or
+	// "fixed by"
+	Relation string
+	Tags     MergeBaseTags
+}
+
+var (
+	changeIDRegexp = regexp.MustCompile(`(?m)^Change-Id:\s*(I[0-9a-f]{40})\s*$`)
+
+	relatedCache cache[[]RelatedCommit]
+)
+
+// relatedCommits returns the commits related to the affected commit,
+// without their tags.
+func relatedCommits(sha1 string) []RelatedCommit {
+	return relatedCache.get(sha1, func() []RelatedCommit {
+		var related []RelatedCommit
+		seen := map[string]bool{sha1: true}
+		add := func(commit, relation string) {
+			if !seen[commit] {
+				seen[commit] = true
+				related = append(related, RelatedCommit{Commit: commit, Relation: relation})
+			}
+		}
+		message := run("git", "show", "--no-patch", "--format=%B", sha1)
+		if m := changeIDRegexp.FindSubmatch(message); m != nil {
+			for _, commit := range logAll("-F", "--grep=Change-Id: "+string(m[1])) {
+				add(commit, "same Change-Id")
+			}
+		}
+		for _, fix := range parseTrailers(message).Fixes {
+			out, err := gitOutput("rev-parse", "--verify", "--quiet", fix+"^{commit}")
+			if err == nil {
+				add(strings.TrimSpace(string(out)), "fixes")
+			}
+		}
+		for _, commit := range logAll("-i", "--grep=^fixes: *"+sha1[:7]) {
+			trailers := parseTrailers(run("git", "show", "--no-patch", "--format=%B", commit))
+			if trailers.references(sha1) {
+				add(commit, "fixed by")
+			}
+		}
+		return related
+	})
+}
+
+// logAll returns the commits of all the refs selected by the git log
+// arguments.
+func logAll(args ...string) []string {
+	var commits []string
+	args = append([]string{"log", "--all", "--format=%H"}, args...)
+	for _, line := range linesFrom("git", args...) {
+		if len(line) > 0 {
+			commits = append(commits, string(line))
+		}
+	}
+	return commits
+}
+
+func showRelated(related []RelatedCommit) {
+	for _, c := range related {
+		fmt.Printf("\t\t%s: %s %s (%s)\n", c.Relation, shortSha1(c.Commit), getCommitSubject(c.Commit), c.Tags)
+	}
+}
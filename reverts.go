@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// Revert is an affected commit that the change undoes, in part or whole.
+// A revert is backported along with the commit it reverts, or not at all,
+// rather than as a new edit.
+type Revert struct {
+	Commit string
+	// Lines of the commit the change undoes, out of all the lines it
+	// changed in the file
+	Undone int
+	Total  int
+}
+
+// lineCounts counts the lines with letters or digits, the others are
+// found in any change.
+func lineCounts(lines unidiff.Lines) map[string]int {
+	counts := map[string]int{}
+	for _, line := range lines {
+		if text := string(line); strings.IndexFunc(text, isAlnum) >= 0 {
+			counts[text]++
+		}
+	}
+	return counts
+}
+
+// findReverts returns the commits whose change to the file the diff
+// undoes: it restores lines they removed, or removes all the lines they
+// only added.
+func findReverts(file string, diff unidiff.Diff, commits []string) []Revert {
+	var removed, added unidiff.Lines
+	for _, hunk := range diff.Hunks {
+		removed = append(removed, hunk.RemovedLines()...)
+		added = append(added, hunk.AddedLines()...)
+	}
+	var reverts []Revert
+	for _, sha1 := range commits {
+		out, err := gitOutput("-c", "diff.noprefix=false", "diff-tree", "-p", "-U0", "--root", "-m",
+			"--first-parent", "--no-commit-id", "--no-ext-diff", "--no-textconv", "--no-color", sha1, "--", file)
+		if err != nil {
+			continue
+		}
+		change, err := unidiff.Parse(bytes.NewReader(out), true)
+		if err != nil {
+			continue
+		}
+		var theirRemoved, theirAdded unidiff.Lines
+		for _, hunk := range change.Hunks {
+			theirRemoved = append(theirRemoved, hunk.RemovedLines()...)
+			theirAdded = append(theirAdded, hunk.AddedLines()...)
+		}
+		theirAddedCounts, theirRemovedCounts := lineCounts(theirAdded), lineCounts(theirRemoved)
+		total := sum(theirAddedCounts) + sum(theirRemovedCounts)
+		onlyAdded := len(theirRemovedCounts) == 0
+		// lines the commit added that are removed, and the other way around
+		dropped := undone(theirAddedCounts, removed)
+		restored := undone(theirRemovedCounts, added)
+		if total == 0 || restored == 0 && !(onlyAdded && dropped == total) {
+			// editing lines of the commit is not undoing it
+			continue
+		}
+		reverts = append(reverts, Revert{Commit: sha1, Undone: dropped + restored, Total: total})
+	}
+	return reverts
+}
+
+func sum(counts map[string]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+// undone returns how many of the lines match those counted, each counted
+// line matching once.
+func undone(counts map[string]int, lines unidiff.Lines) int {
+	n := 0
+	for _, line := range lines {
+		if counts[string(line)] > 0 {
+			counts[string(line)]--
+			n++
+		}
+	}
+	return n
+}
+
+func showReverts(reverts []Revert) {
+	for _, r := range reverts {
+		if r.Undone == r.Total {
+			fmt.Printf("    Reverts: %s %s\n", shortSha1(r.Commit), getCommitSubject(r.Commit))
+		} else {
+			fmt.Printf("    Partly reverts: %s %s (%d of %d lines)\n",
+				shortSha1(r.Commit), getCommitSubject(r.Commit), r.Undone, r.Total)
+		}
+	}
+}
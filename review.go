@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reviewRefs is the namespace the heads of pull requests and the like are
+// fetched into, until they are resolved to commits.
+const reviewRefs = "refs/check-diff/review/"
+
+var pullRequestURLRegexp = regexp.MustCompile(`^https?://([^/]+)/([^/]+/[^/]+?)(?:\.git)?/pull/(\d+)/?$`)
+
+// fetchPullRequest fetches the head of the GitHub pull request, given by
+// number or URL, and returns the commit and the one of the branch it
+// targets, see mergeTarget. For URLs the pull request is fetched from the
+// remote of that repository, if there is one, or from the URL of the
+// repository.
+func fetchPullRequest(pr string) (head, target string) {
+	remote := remotes[0]
+	number := pr
+	if m := pullRequestURLRegexp.FindStringSubmatch(pr); m != nil {
+		remote = remoteFor(m[1], m[2])
+		if remote == "" {
+			remote = fmt.Sprintf("https://%s/%s.git", m[1], m[2])
+		}
+		number = m[3]
+	} else if n, err := strconv.Atoi(pr); err != nil || n <= 0 {
+		bail("error: -pr %s: not a pull request number or URL", pr)
+	}
+	head = fetchReviewRef(remote, "refs/pull/"+number+"/head", "pull/"+number)
+	return head, mergeTarget(remote, "refs/pull/"+number+"/merge", "pull/"+number+"-merge")
+}
+
+// remoteFor returns the remote fetching the repository path (e.g.
+// org/repo) from host, "" if there is none.
+func remoteFor(host, path string) string {
+	out, _ := gitOutput("config", "--get-regexp", `^remote\..*\.url$`)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		url := strings.TrimSuffix(strings.TrimSuffix(fields[1], "/"), ".git")
+		if strings.Contains(url, host) && (strings.HasSuffix(url, "/"+path) || strings.HasSuffix(url, ":"+path)) {
+			name := strings.TrimPrefix(fields[0], "remote.")
+			return strings.TrimSuffix(name, ".url")
+		}
+	}
+	return ""
+}
+
+// fetchReviewRef fetches ref from remote and returns the commit. The ref
+// is fetched into the review namespace under name and removed once
+// resolved, so that nothing is left behind even if git check-diff is
+// interrupted.
+func fetchReviewRef(remote, ref, name string) string {
+	local := reviewRefs + name
+	if _, err := gitEnv(nil, fetchArgs(remote, "+"+ref+":"+local)...); err != nil {
+		bail("error: cannot fetch %s from %s", ref, remote)
+	}
+	commit := revParse(local)
+	if _, err := gitOutput("update-ref", "-d", local); err != nil {
+		warn("warning: cannot remove %s", local)
+	}
+	return commit
+}
+
+// mergeTarget fetches the merge ref the forge prepares for a pull or merge
+// request and returns its first parent, the tip of the branch the request
+// targets. It returns "" when there is no such ref, as when the changes
+// conflict with the branch.
+func mergeTarget(remote, ref, name string) string {
+	local := reviewRefs + name
+	if _, err := gitOutput(fetchArgs(remote, "+"+ref+":"+local)...); err != nil {
+		slog.Debug("no merge ref", "remote", remote, "ref", ref, "err", err)
+		return ""
+	}
+	target := revParse(local + "^1")
+	if _, err := gitOutput("update-ref", "-d", local); err != nil {
+		warn("warning: cannot remove %s", local)
+	}
+	return target
+}
+
+// reviewBase returns the ref changes under review are checked against:
+// -base-ref, the branch the request targets or the default branch.
+func reviewBase(target string) string {
+	if optBaseRef != "" {
+		return optBaseRef
+	}
+	if target != "" {
+		return target
+	}
+	if len(defaultBranches) == 0 {
+		bail("error: no default branch found for %s, see -default-branch", strings.Join(remotes, ", "))
+	}
+	return defaultBranches[0]
+}
+
+// reviewBaseName names the base of a review for the reports.
+func reviewBaseName(base, target string) string {
+	if base == target {
+		return "its target branch at " + shortSha1(base)
+	}
+	return base
+}
+
+var (
+	scpURLRegexp = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+	urlRegexp    = regexp.MustCompile(`^[a-z+]+://(?:[^@/]+@)?([^/:]+)(?::\d+)?/(.+)$`)
+)
+
+// remoteHostPath returns the host and repository path (e.g. group/project)
+// of the URL of remote, or "" when the URL is not a network one.
+func remoteHostPath(remote string) (host, path string) {
+	out, err := gitOutput("config", "--get", "remote."+remote+".url")
+	if err != nil {
+		return "", ""
+	}
+	u := strings.TrimSpace(string(out))
+	m := urlRegexp.FindStringSubmatch(u)
+	if m == nil {
+		m = scpURLRegexp.FindStringSubmatch(u)
+	}
+	if m == nil || strings.HasPrefix(u, "/") || strings.HasPrefix(u, "file:") {
+		return "", ""
+	}
+	return m[1], strings.TrimSuffix(strings.TrimSuffix(m[2], "/"), ".git")
+}
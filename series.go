@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Patch is a patch of a series prepared with git format-patch, or a mail
+// of an mbox.
+type Patch struct {
+	// Name shown for the patch: its file name, with the position of the
+	// message for mboxes
+	Name string
+	// The patch, without the mail headers and message
+	File    string
+	Subject string
+	// Commit the patch was made from, if it is in this repository
+	From string
+	// Commit the series applies to, given by format-patch --base
+	Base string
+}
+
+var (
+	fromLineRegexp   = regexp.MustCompile(`^From ([0-9a-f]{40}) `)
+	baseCommitRegexp = regexp.MustCompile(`^base-commit: ([0-9a-f]{40})$`)
+)
+
+// patchFiles returns the patch and mbox files given as arguments, the
+// *.patch files of the directories in name order.
+func patchFiles(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		arg = userPath(arg)
+		fi, err := os.Stat(arg)
+		if err != nil {
+			bail("error: %v", err)
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.patch"))
+		if err != nil {
+			bail("error: %v", err)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// readPatches splits the mbox (a single patch is an mbox of one message)
+// into its messages in dir and returns their patches. A file that is not an
+// mbox, such as the output of git diff, is taken as a single message. The
+// messages without a patch, such as cover letters, are left out.
+func readPatches(file, dir string) []Patch {
+	if err := os.Mkdir(dir, 0700); err != nil {
+		bail("error: %v", err)
+	}
+	out, err := gitOutput("mailsplit", "-b", "-o"+dir, file)
+	if err != nil {
+		bail("error: %s: not a patch or mbox file", file)
+	}
+	var n int
+	fmt.Sscan(string(out), &n)
+
+	var patches []Patch
+	for i := 1; i <= n; i++ {
+		name := filepath.Base(file)
+		if n > 1 {
+			name = fmt.Sprintf("%s#%d", name, i)
+		}
+		msg := filepath.Join(dir, fmt.Sprintf("%04d", i))
+		p := readPatch(msg, name)
+		if fi, err := os.Stat(p.File); err == nil && fi.Size() > 0 {
+			patches = append(patches, p)
+		}
+	}
+	return patches
+}
+
+// readPatch reads the mail in file, extracting its patch next to it.
+func readPatch(file, name string) Patch {
+	p := Patch{Name: name, File: file + ".patch"}
+	info, err := gitInput(file, "mailinfo", os.DevNull, p.File)
+	if err != nil {
+		bail("error: %s: %v", name, err)
+	}
+	for _, line := range strings.Split(string(info), "\n") {
+		if strings.HasPrefix(line, "Subject: ") {
+			p.Subject = strings.TrimPrefix(line, "Subject: ")
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		bail("error: %v", err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for first := true; s.Scan(); first = false {
+		if m := fromLineRegexp.FindStringSubmatch(s.Text()); first && m != nil {
+			if _, err := gitOutput("rev-parse", "-q", "--verify", m[1]+"^{commit}"); err == nil {
+				p.From = m[1]
+			}
+		}
+		if m := baseCommitRegexp.FindStringSubmatch(s.Text()); m != nil {
+			p.Base = m[1]
+		}
+	}
+	return p
+}
+
+// checkSeries checks each patch of a format-patch series, or each mail of
+// mboxes, against the state of the files it applies to, and the series as
+// a whole. The patches made from commits of this repository are checked
+// against those commits, the others are applied, in an index of their own,
+// on top of the previous patch, the base commit of the series, or HEAD.
+func checkSeries(args []string) {
+	tmpdir, err := ioutil.TempDir("", "git-check-diff")
+	if err != nil {
+		bail("error: %v", err)
+	}
+	onExit(func() { os.RemoveAll(tmpdir) })
+	index := filepath.Join(tmpdir, "index")
+
+	var patches []Patch
+	for i, file := range patchFiles(args) {
+		patches = append(patches, readPatches(file, filepath.Join(tmpdir, fmt.Sprint(i)))...)
+	}
+	if len(patches) == 0 {
+		bail("error: no patches found in %s", strings.Join(args, " "))
+	}
+
+	// the commits of the patches, the lines they still own are new
+	var commits []string
+	var specs []DiffSpec
+	prev := ""
+	for _, p := range patches {
+		if p.From != "" {
+			parent := revParse(p.From + "^")
+			if prev == "" || parent == prev {
+				specs = append(specs, DiffSpec{Revs: []string{parent, p.From}, BlameRev: parent})
+				commits = append(commits, p.From)
+				prev = p.From
+				continue
+			}
+		}
+		if prev == "" {
+			prev = "HEAD"
+			if p.Base != "" {
+				prev = p.Base
+			}
+			prev = revParse(prev)
+		}
+		commit := applyPatch(index, prev, p)
+		specs = append(specs, DiffSpec{Revs: []string{prev, commit}, BlameRev: prev})
+		commits = append(commits, commit)
+		prev = commit
+	}
+
+	var all []*FileReport
+	for i, p := range patches {
+		spec := specs[i]
+		spec.IgnoreRevs = commits
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("[%d/%d] %s: %s\n", i+1, len(patches), p.Name, p.Subject)
+		files := changedFiles(spec)
+		if len(files) == 0 {
+			fmt.Printf("    No existing lines changed.\n")
+			continue
+		}
+		var reports []*FileReport
+		for j := range analyzeFiles(files, nil, spec) {
+			reports = append(reports, j.report)
+		}
+		sort.Slice(reports, func(a, b int) bool { return reports[a].File < reports[b].File })
+		for _, r := range reports {
+			showReport(r)
+		}
+		if len(reports) > 1 {
+			if commonTags := commonTagsOf(reports); len(commonTags) > 0 {
+				fmt.Printf("PATCH COMMON TAG: %s\n", commonTags)
+			} else {
+				fmt.Printf("NO PATCH COMMON TAG\n")
+			}
+		}
+		all = append(all, reports...)
+	}
+
+	if len(patches) > 1 && len(all) > 0 {
+		fmt.Println()
+		showSummary("SERIES ", all, commonTagsOf(all))
+	}
+}
+
+func revParse(rev string) string {
+	out, err := gitOutput("rev-parse", "-q", "--verify", rev+"^{commit}")
+	if err != nil {
+		if rev == "HEAD" && unbornHead() {
+			bail("error: HEAD has no commits yet")
+		}
+		bail("error: %s: not a commit", rev)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// applyPatch applies the patch on top of commit using the index file
+// given, and returns the (unreferenced) commit made of the result.
+func applyPatch(index, commit string, p Patch) string {
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := gitEnv(env, "read-tree", commit); err != nil {
+		bail("error: %s: %v", commit, err)
+	}
+	if _, err := gitEnv(env, "apply", "--cached", p.File); err != nil {
+		bail("error: %s does not apply on %s", p.Name, shortSha1(commit))
+	}
+	tree, err := gitEnv(env, "write-tree")
+	if err != nil {
+		bail("error: %v", err)
+	}
+	env = append(env,
+		"GIT_AUTHOR_NAME=git check-diff", "GIT_AUTHOR_EMAIL=git-check-diff",
+		"GIT_COMMITTER_NAME=git check-diff", "GIT_COMMITTER_EMAIL=git-check-diff")
+	out, err := gitEnv(env, "commit-tree", "-p", commit, "-m", p.Subject, strings.TrimSpace(string(tree)))
+	if err != nil {
+		bail("error: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitEnv runs git with the additional environment variables.
+func gitEnv(env []string, arg ...string) ([]byte, error) {
+	acquireProc()
+	defer releaseProc()
+	cmd, done := command("git", arg...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return out, done(err)
+}
+
+// gitInput runs git with the content of file as its standard input.
+func gitInput(file string, arg ...string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	acquireProc()
+	defer releaseProc()
+	cmd, done := command("git", arg...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return out, done(err)
+}
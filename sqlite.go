@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	started TEXT NOT NULL,
+	head TEXT NOT NULL,
+	cached INTEGER NOT NULL,
+	offset INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	path TEXT NOT NULL,
+	removed INTEGER NOT NULL,
+	added INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS hunks (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	number INTEGER NOT NULL,
+	removed_start INTEGER NOT NULL,
+	removed_count INTEGER NOT NULL,
+	added_start INTEGER NOT NULL,
+	added_count INTEGER NOT NULL,
+	PRIMARY KEY (file_id, number)
+);
+CREATE TABLE IF NOT EXISTS commits (
+	sha TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS lines (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	commit_sha TEXT NOT NULL REFERENCES commits(sha),
+	line INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tags (
+	name TEXT PRIMARY KEY,
+	number INTEGER
+);
+CREATE TABLE IF NOT EXISTS commit_tags (
+	commit_sha TEXT NOT NULL REFERENCES commits(sha),
+	tag TEXT NOT NULL REFERENCES tags(name),
+	PRIMARY KEY (commit_sha, tag)
+);
+CREATE TABLE IF NOT EXISTS branches (
+	name TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS commit_branches (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	commit_sha TEXT NOT NULL REFERENCES commits(sha),
+	branch TEXT NOT NULL REFERENCES branches(name),
+	PRIMARY KEY (run_id, commit_sha, branch)
+);
+CREATE TABLE IF NOT EXISTS file_common_tags (
+	file_id INTEGER NOT NULL REFERENCES files(id),
+	tag TEXT NOT NULL REFERENCES tags(name),
+	PRIMARY KEY (file_id, tag)
+);
+`
+
+// writeSqlite appends the results of this run to the SQLite database at
+// filename using the sqlite3 command line shell.
+func writeSqlite(filename string, reports []*FileReport) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "PRAGMA foreign_keys = ON;\n")
+	fmt.Fprintf(b, "BEGIN;\n")
+	b.WriteString(sqliteSchema)
+
+	head := strings.TrimSpace(string(run("git", "rev-parse", "HEAD")))
+	fmt.Fprintf(b, "INSERT INTO runs (started, head, cached, offset) VALUES (%s, %s, %d, %d);\n",
+		sqlQuote(time.Now().UTC().Format(time.RFC3339)), sqlQuote(head), sqlBool(optCached), optOffset)
+
+	const runID = "(SELECT max(id) FROM runs)"
+	const fileID = "(SELECT max(id) FROM files)"
+	for _, r := range reports {
+		fmt.Fprintf(b, "INSERT INTO files (run_id, path, removed, added) VALUES (%s, %s, %d, %d);\n",
+			runID, sqlQuote(r.File), r.Diff.Removed, r.Diff.Added)
+		for i, hunk := range r.Diff.Hunks {
+			fmt.Fprintf(b, "INSERT INTO hunks VALUES (%s, %d, %d, %d, %d, %d);\n",
+				fileID, i+1, hunk.Removed.Start, hunk.Removed.Count, hunk.Added.Start, hunk.Added.Count)
+		}
+
+		var commits []string
+		for sha1 := range r.Commits {
+			commits = append(commits, sha1)
+		}
+		sort.Strings(commits)
+		for _, sha1 := range commits {
+			fmt.Fprintf(b, "INSERT OR IGNORE INTO commits VALUES (%s);\n", sqlQuote(sha1))
+			for _, lnum := range r.Lines[sha1] {
+				fmt.Fprintf(b, "INSERT INTO lines VALUES (%s, %s, %d);\n", fileID, sqlQuote(sha1), lnum)
+			}
+			for _, tag := range r.Commits[sha1] {
+				number := "NULL"
+				if n, ok := getTagNumber(tag); ok {
+					number = strconv.Itoa(n)
+				}
+				fmt.Fprintf(b, "INSERT OR IGNORE INTO tags VALUES (%s, %s);\n", sqlQuote(tag), number)
+				fmt.Fprintf(b, "INSERT OR IGNORE INTO commit_tags VALUES (%s, %s);\n", sqlQuote(sha1), sqlQuote(tag))
+			}
+			for _, branch := range r.Branches[sha1] {
+				fmt.Fprintf(b, "INSERT OR IGNORE INTO branches VALUES (%s);\n", sqlQuote(branch))
+				fmt.Fprintf(b, "INSERT OR IGNORE INTO commit_branches VALUES (%s, %s, %s);\n",
+					runID, sqlQuote(sha1), sqlQuote(branch))
+			}
+		}
+		for _, tag := range r.CommonTags {
+			fmt.Fprintf(b, "INSERT INTO file_common_tags VALUES (%s, %s);\n", fileID, sqlQuote(tag))
+		}
+	}
+	fmt.Fprintf(b, "COMMIT;\n")
+
+	cmd := exec.Command("sqlite3", "-bail", filename)
+	cmd.Stdin = b
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sqlite3 %s: %v", filename, err)
+	}
+	return nil
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
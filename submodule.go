@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SubmoduleChange is a change of the commit a submodule points to.
+type SubmoduleChange struct {
+	// Old and new commits, "" when the submodule is added or removed
+	Old string
+	New string
+	// Whether the work tree of the submodule has uncommitted changes
+	Dirty bool
+	// Report of the changes inside the submodule, with -recurse-submodules
+	Report string
+}
+
+var subprojectCommitPrefix = []byte("Subproject commit ")
+
+// isSubmodule tells whether the path is a submodule: a directory is not
+// given to git check-diff otherwise.
+func isSubmodule(file string) bool {
+	fi, err := os.Stat(file)
+	return err == nil && fi.IsDir()
+}
+
+// submoduleChange returns the change of the submodule made by spec, read
+// from the "Subproject commit" lines git diff shows for it.
+func submoduleChange(file string, spec DiffSpec) *SubmoduleChange {
+	args := diffArgs("--submodule=short")
+	args = append(append(args, spec.Revs...), "--", file)
+	c := &SubmoduleChange{}
+	for _, line := range linesFrom("git", args...) {
+		if len(line) == 0 || !bytes.HasPrefix(line[1:], subprojectCommitPrefix) {
+			continue
+		}
+		sha1 := string(line[1+len(subprojectCommitPrefix):])
+		if strings.HasSuffix(sha1, "-dirty") {
+			sha1 = strings.TrimSuffix(sha1, "-dirty")
+			c.Dirty = true
+		}
+		switch line[0] {
+		case '-':
+			c.Old = sha1
+		case '+':
+			c.New = sha1
+		}
+	}
+	if optRecurseSubmodules && c.Old != "" && c.New != "" && c.Old != c.New {
+		c.Report = checkSubmodule(file, c)
+	}
+	return c
+}
+
+// checkSubmodule runs git check-diff in the submodule on the changes
+// between its old and new commits, and returns its output.
+func checkSubmodule(file string, c *SubmoduleChange) string {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	acquireProc()
+	defer releaseProc()
+	cmd, done := command(exe, "-range", c.Old+"..."+c.New, "-recurse-submodules")
+	cmd.Dir = file
+	out, err := cmd.CombinedOutput()
+	err = done(err)
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	return string(out)
+}
+
+func showSubmodule(c *SubmoduleChange) {
+	old, new := "none", "none"
+	if c.Old != "" {
+		old = shortSha1(c.Old)
+	}
+	if c.New != "" {
+		new = shortSha1(c.New)
+	}
+	fmt.Printf("    Submodule: %s -> %s", old, new)
+	if c.Dirty {
+		fmt.Printf(" (with uncommitted changes)")
+	}
+	fmt.Println()
+	for _, line := range strings.SplitAfter(c.Report, "\n") {
+		if line != "" {
+			fmt.Printf("\t%s", line)
+		}
+	}
+}
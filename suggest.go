@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// suggestBase returns the release branch to base a fix on so that it
+// merges cleanly: the one of the merge base tag, release-N for
+// MERGE_BASE_N, or the oldest newer one when it is gone, looked for in the
+// order of the remotes. It returns "" when no release is recent enough, or
+// the tag has no number.
+func suggestBase(tag string, releases, remotes []string) string {
+	n, ok := getTagNumber(tag)
+	if !ok {
+		return ""
+	}
+	for _, remote := range remotes {
+		best, bestN := "", 0
+		for _, b := range releases {
+			v, ok := releaseNumber(b)
+			if !ok || v < n || !strings.HasPrefix(b, remote+"/") {
+				continue
+			}
+			if best == "" || v < bestN {
+				best, bestN = b, v
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
+
+// showSuggestion recommends the branch to develop the fix on, from the
+// oldest common merge base tag of the affected commits, or the default
+// branch when no tag has them all, and the branches the fix then flows
+// through.
+func showSuggestion(commonTags MergeBaseTags, pattern, indent string) {
+	why := "no merge base tag contains all the affected commits"
+	base := ""
+	if len(commonTags) > 0 {
+		why = "merge base " + commonTags[0]
+		base = suggestBase(commonTags[0], activeReleases(pattern), remotes)
+	}
+	if base != "" {
+		fmt.Printf("%sSuggestion: base your fix on %s (%s), then merge upward\n", indent, base, why)
+		chain := propagation(base, activeReleases(pattern), defaultBranches, forkDepth)
+		fmt.Printf("%sMerge path: %s\n", indent, strings.Join(chain, " -> "))
+	} else if len(defaultBranches) > 0 {
+		fmt.Printf("%sSuggestion: base your fix on %s (%s)\n", indent, defaultBranches[0], why)
+	}
+}
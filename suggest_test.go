@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestSuggestBase(t *testing.T) {
+	releases := []string{"origin/release-12", "origin/release-14", "origin/release-15", "upstream/release-13"}
+	tests := []struct {
+		tag     string
+		remotes []string
+		want    string
+	}{
+		{"MERGE_BASE_14", []string{"origin"}, "origin/release-14"},
+		{"MERGE_BASE_13", []string{"origin"}, "origin/release-14"},
+		{"MERGE_BASE_13", []string{"upstream", "origin"}, "upstream/release-13"},
+		{"MERGE_BASE_16", []string{"origin", "upstream"}, ""},
+		{"v1.1", []string{"origin"}, ""},
+	}
+	for _, tt := range tests {
+		if got := suggestBase(tt.tag, releases, tt.remotes); got != tt.want {
+			t.Errorf("suggestBase(%q, %q) = %q, want %q", tt.tag, tt.remotes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/holygeek/git-check-diff/internal/unidiff"
+)
+
+// symlinkMode is the git mode of symbolic links.
+const symlinkMode = "120000"
+
+// SymlinkChange is a change of the target of a symbolic link. Blaming its
+// single line tells nothing useful, the commit that last set the link is
+// the affected one.
+type SymlinkChange struct {
+	// Old and new targets, "" when the file is not a link on that side
+	Old string
+	New string
+	// Commit that last set the link, "" for a new link
+	Commit string
+}
+
+// symlinkChange returns the change of the symbolic link made by spec.
+func symlinkChange(file string, spec DiffSpec, diff unidiff.Diff) *SymlinkChange {
+	c := &SymlinkChange{}
+	if diff.OldMode == symlinkMode {
+		c.Old = blobContent(spec.BlameRev, file)
+		out, err := gitOutput("log", "-1", "--format=%H", spec.BlameRev, "--", file)
+		if err == nil {
+			c.Commit = strings.TrimSpace(string(out))
+		}
+	}
+	if diff.NewMode == symlinkMode {
+		switch revs := spec.Revs; {
+		case len(revs) == 1 && revs[0] == "--cached":
+			c.New = blobContent("", file)
+		case len(revs) == 2:
+			c.New = blobContent(revs[1], file)
+		default:
+			// the work tree
+			c.New, _ = os.Readlink(file)
+		}
+	}
+	return c
+}
+
+// blobContent returns the content of the file in the revision, or in the
+// index for "".
+func blobContent(rev, file string) string {
+	out, err := gitOutput("cat-file", "blob", rev+":./"+filepath.ToSlash(file))
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func showSymlink(c *SymlinkChange) {
+	old, new := c.Old, c.New
+	if old == "" {
+		old = "(not a link)"
+	}
+	if new == "" {
+		new = "(not a link)"
+	}
+	fmt.Printf("    Symlink target: %s -> %s\n", old, new)
+}
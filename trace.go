@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// traceCommand shows the git command run, how long it took and how it
+// ended, for -trace.
+func traceCommand(arg []string, d time.Duration, err error) {
+	status := "exit 0"
+	if e, ok := err.(*exec.ExitError); ok {
+		status = fmt.Sprintf("exit %d", e.ExitCode())
+	} else if err != nil {
+		status = err.Error()
+	}
+	warn("trace: git %s (%v, %s)", quoteArgs(arg), d.Round(time.Microsecond*100), status)
+}
+
+// quoteArgs joins the arguments with spaces, quoting the ones that would
+// not read back as one.
+func quoteArgs(arg []string) string {
+	quoted := make([]string, len(arg))
+	for i, a := range arg {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'\\") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emitTrailers prints a Fixes: trailer for every affected commit and a
+// Backport-to: trailer for every release branch containing all of them.
+func emitTrailers(reports []*FileReport) {
+	seen := map[string]bool{}
+	var commits []string
+	branchCount := map[string]int{}
+	for _, r := range reports {
+		for _, sha1 := range r.sortedCommits() {
+			if seen[sha1] {
+				continue
+			}
+			seen[sha1] = true
+			commits = append(commits, sha1)
+			for _, b := range r.Branches[sha1] {
+				branchCount[b]++
+			}
+		}
+	}
+	dates := map[string]time.Time{}
+	for _, sha1 := range commits {
+		dates[sha1] = getCommitDate(sha1)
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return dates[commits[i]].Before(dates[commits[j]])
+	})
+
+	var branches []string
+	for b, count := range branchCount {
+		if _, ok := releaseNumber(b); ok && count == len(commits) {
+			branches = append(branches, b)
+		}
+	}
+	sortBranches(branches)
+
+	for _, sha1 := range commits {
+		fmt.Printf("Fixes: %s (\"%s\")\n", shortSha1(sha1), getCommitSubject(sha1))
+	}
+	printed := map[string]bool{}
+	for _, b := range branches {
+		// The same release may exist on more than one remote
+		release := b[strings.LastIndex(b, "/")+1:]
+		if !printed[release] {
+			printed[release] = true
+			fmt.Printf("Backport-to: %s\n", release)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var containingCache cache[[]string]
+
+// refsContaining returns the local and remote branches containing the
+// commit.
+func refsContaining(sha1 string) []string {
+	return containingCache.get(sha1, func() []string {
+		var refs []string
+		for _, line := range linesFrom("git", "for-each-ref", "--contains", sha1,
+			"--format=%(refname:short)", "refs/heads", "refs/remotes") {
+			if len(line) > 0 && !bytes.HasSuffix(line, []byte("/HEAD")) {
+				refs = append(refs, string(line))
+			}
+		}
+		return refs
+	})
+}
+
+// unmergedCommits returns the feature branches holding each of the
+// commits that no checked branch contains: the lines were changed by work
+// still in progress.
+func unmergedCommits(commits []string, branches map[string][]string) map[string][]string {
+	unmerged := map[string][]string{}
+	for _, sha1 := range commits {
+		if len(branches[sha1]) == 0 {
+			unmerged[sha1] = refsContaining(sha1)
+		}
+	}
+	return unmerged
+}
+
+func showUnmerged(r *FileReport) {
+	if len(r.Unmerged) == 0 {
+		return
+	}
+	fmt.Printf("    Warning: some affected commits are not merged into the default or release\n")
+	fmt.Printf("    branches yet, the change may conflict with the work in progress:\n")
+	for _, sha1 := range r.sortedCommits() {
+		refs, ok := r.Unmerged[sha1]
+		if !ok {
+			continue
+		}
+		on := "no branch"
+		if len(refs) > 0 {
+			on = strings.Join(refs, " ")
+		}
+		fmt.Printf("\t%s on %s\n", shortSha1(sha1), on)
+	}
+}
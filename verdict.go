@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// activeReleases returns the release branches of the remotes matching
+// pattern, without the default branches and the local ones.
+func activeReleases(pattern string) []string {
+	var releases []string
+	for _, b := range getCheckedBranches(pattern) {
+		for _, remote := range remotes {
+			if ok, _ := path.Match(remote+"/"+pattern, b.name); ok {
+				releases = append(releases, b.name)
+				break
+			}
+		}
+	}
+	return releases
+}
+
+// notEverywhere returns, for each affected commit of the report missing from
+// some of the releases or from the newest merge base tag, what it is
+// missing from.
+func notEverywhere(r *FileReport, releases []string, newest string) map[string][]string {
+	missing := map[string][]string{}
+	for _, sha1 := range r.sortedCommits() {
+		in := map[string]bool{}
+		for _, b := range r.Branches[sha1] {
+			in[b] = true
+		}
+		for _, b := range releases {
+			if !in[b] {
+				missing[sha1] = append(missing[sha1], b)
+			}
+		}
+		if newest != "" && !r.Commits[sha1].contains(newest) {
+			missing[sha1] = append(missing[sha1], newest)
+		}
+	}
+	return missing
+}
+
+// showVerdict tells whether the code the change touches is in every active
+// release, so that the change can be made without backporting, or lists
+// where each affected commit is missing.
+func showVerdict(r *FileReport) {
+	if len(r.Commits) == 0 {
+		return
+	}
+	newest := ""
+	if tags := listTags(r.Project.Tags); len(tags) > 0 {
+		newest = tags[len(tags)-1].name
+	}
+	missing := notEverywhere(r, activeReleases(r.Project.Branches), newest)
+	if len(missing) == 0 {
+		fmt.Printf("    SAFE: all touched code present in every active release\n")
+		return
+	}
+	fmt.Printf("    NOT SAFE: touched code missing from some releases:\n")
+	for _, sha1 := range r.sortedCommits() {
+		if m := missing[sha1]; len(m) > 0 {
+			fmt.Printf("\t%s not in %s\n", shortSha1(sha1), strings.Join(m, " "))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotEverywhere(t *testing.T) {
+	r := &FileReport{
+		Commits: map[string]MergeBaseTags{
+			"a": {"MERGE_BASE_1", "MERGE_BASE_2"},
+			"b": {"MERGE_BASE_2"},
+			"c": nil,
+		},
+		Branches: map[string][]string{
+			"a": {"origin/develop", "origin/release-1", "origin/release-2"},
+			"b": {"origin/develop", "origin/release-2"},
+			"c": {"origin/develop"},
+		},
+	}
+	releases := []string{"origin/release-1", "origin/release-2"}
+
+	got := notEverywhere(r, releases, "MERGE_BASE_2")
+	want := map[string][]string{
+		"b": {"origin/release-1"},
+		"c": {"origin/release-1", "origin/release-2", "MERGE_BASE_2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %q\n got: %q", want, got)
+	}
+
+	delete(r.Commits, "b")
+	delete(r.Commits, "c")
+	if got := notEverywhere(r, releases, "MERGE_BASE_2"); len(got) != 0 {
+		t.Errorf("want nothing missing, got: %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Trailers holds the trailers of a commit message that git check-diff
+// cares about.
+type Trailers struct {
+	// Commit ids given in Fixes: trailers
+	Fixes []string
+	// Release names given in Backport-to: trailers
+	BackportTo []string
+}
+
+// parseTrailers collects the Fixes: and Backport-to: trailers found in the
+// commit message.
+func parseTrailers(message []byte) Trailers {
+	var t Trailers
+	s := bufio.NewScanner(bytes.NewReader(message))
+	for s.Scan() {
+		line := s.Text()
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(line[:i])) {
+		case "fixes":
+			t.Fixes = append(t.Fixes, fields[0])
+		case "backport-to":
+			t.BackportTo = append(t.BackportTo, fields[0])
+		}
+	}
+	return t
+}
+
+// references returns true when one of the Fixes: trailers names sha1.
+func (t Trailers) references(sha1 string) bool {
+	for _, fix := range t.Fixes {
+		if len(fix) >= 7 && strings.HasPrefix(sha1, fix) {
+			return true
+		}
+	}
+	return false
+}
+
+// targets returns true when one of the Backport-to: trailers names a
+// release branch containing all of the affected commits of the reports.
+func (t Trailers) targets(reports []*FileReport) bool {
+	for _, release := range t.BackportTo {
+		contained := true
+		for _, r := range reports {
+			for sha1 := range r.Commits {
+				found := false
+				for _, b := range r.Branches[sha1] {
+					if b == release || strings.HasSuffix(b, "/"+release) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					contained = false
+				}
+			}
+		}
+		if contained {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTrailers checks that the message of every commit in the revision
+// range references the commits whose lines it modifies, either through
+// Fixes: trailers or a Backport-to: trailer for a release containing them.
+// It returns false when a commit fails the check.
+func verifyTrailers(revs []string) bool {
+	ok := true
+	args := append([]string{"rev-list", "--reverse", "--no-merges"}, revs...)
+	for _, line := range linesFrom("git", args...) {
+		sha1 := string(line)
+		if len(sha1) == 0 {
+			continue
+		}
+		parents := strings.Fields(string(linesFrom("git", "show", "--no-patch", "--format=%P", sha1)[0]))
+		if len(parents) == 0 {
+			fmt.Printf("%s root commit, skipped\n", shortSha1(sha1))
+			continue
+		}
+
+		var reports []*FileReport
+		spec := DiffSpec{Revs: []string{parents[0], sha1}, BlameRev: parents[0]}
+		for _, file := range changedFiles(spec) {
+			reports = append(reports, analyzeFile(file, nil, spec))
+		}
+
+		trailers := parseTrailers(run("git", "show", "--no-patch", "--format=%B", sha1))
+		var missing []string
+		seen := map[string]bool{}
+		for _, r := range reports {
+			for _, affected := range r.sortedCommits() {
+				if !seen[affected] && !trailers.references(affected) {
+					missing = append(missing, shortSha1(affected))
+				}
+				seen[affected] = true
+			}
+		}
+
+		subject := getCommitSubject(sha1)
+		if len(missing) == 0 || trailers.targets(reports) {
+			fmt.Printf("%s OK %s\n", shortSha1(sha1), subject)
+			continue
+		}
+		ok = false
+		fmt.Printf("%s FAIL %s\n", shortSha1(sha1), subject)
+		fmt.Printf("\tmissing Fixes: for %s\n", strings.Join(missing, " "))
+	}
+	return ok
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		message string
+		want    Trailers
+	}{
+		{
+			message: "Fix crash\n\nSome text: here\n\nFixes: 1234567890ab (\"Add crash\")\nBackport-to: release-12\n",
+			want: Trailers{
+				Fixes:      []string{"1234567890ab"},
+				BackportTo: []string{"release-12"},
+			},
+		},
+		{
+			message: "Fix crash\n\nfixes:abcdef0\nFixes:\n",
+			want: Trailers{
+				Fixes: []string{"abcdef0"},
+			},
+		},
+		{
+			message: "No trailers\n",
+			want:    Trailers{},
+		},
+	}
+
+	for i, tt := range tests {
+		got := parseTrailers([]byte(tt.message))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tests[%d] failed\nwant: %+v\n got: %+v", i, tt.want, got)
+		}
+	}
+}
+
+func TestTrailersReferences(t *testing.T) {
+	trailers := Trailers{Fixes: []string{"271df90", "98f4"}}
+	if !trailers.references("271df90591e6620da824f4baccc612e9a8213a03") {
+		t.Errorf("271df90 should reference 271df90591e6...")
+	}
+	if trailers.references("98f446fc58ad2c61621c9a0719b6007e93c2ea44") {
+		t.Errorf("98f4 is too short to reference 98f446fc58ad...")
+	}
+}
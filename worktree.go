@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// userDir is the directory git check-diff was started in, before -worktree
+// moved to another worktree.
+var userDir string
+
+// useWorktree moves to the worktree of the repository given by its path,
+// directory name or branch, in the directory matching the current one when
+// it exists, so that file arguments name the same files there.
+func useWorktree(name string) {
+	dir := findWorktree(name)
+	if dir == "" {
+		bail("error: %s: no such worktree (see git worktree list)", name)
+	}
+	prefix := strings.TrimSpace(string(run("git", "rev-parse", "--show-prefix")))
+	if fi, err := os.Stat(filepath.Join(dir, prefix)); err == nil && fi.IsDir() {
+		dir = filepath.Join(dir, prefix)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		bail("error: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		bail("error: %v", err)
+	}
+	userDir = cwd
+}
+
+// findWorktree returns the path of the worktree listed by git worktree list
+// with the path, directory name or branch given, or "" if there is none.
+func findWorktree(name string) string {
+	abs, _ := filepath.Abs(name)
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	var dir string
+	for _, line := range linesFrom("git", "worktree", "list", "--porcelain") {
+		s := string(line)
+		switch {
+		case strings.HasPrefix(s, "worktree "):
+			dir = strings.TrimPrefix(s, "worktree ")
+			if dir == abs || filepath.Base(dir) == name {
+				return dir
+			}
+		case strings.HasPrefix(s, "branch "):
+			if strings.TrimPrefix(s, "branch refs/heads/") == name {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+
+// userPath returns the path to the file named on the command line, which
+// is relative to the directory git check-diff was started in.
+func userPath(file string) string {
+	if userDir == "" || file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(userDir, file)
+}